@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	kln "github.com/adelmoradian/kln/pkg"
+	"github.com/spf13/cobra"
+)
+
+var checkResourceVersion bool
+
+var applyCmd = &cobra.Command{
+	Use:   "apply PLAN",
+	Short: "Deletes the objects of a plan",
+	Long: `Deletes exactly the objects that were recorded by "kln plan". Every
+deletion is pinned to the UID of the planned object, so objects that were
+deleted and created again since the plan was made are skipped and reported,
+as are objects that no longer exist or that the safety policy of the plan
+protects. With --check-resource-version, objects that changed in any way
+since the plan was made are skipped too. The resource identifier file is
+not read.`,
+	Example: `# Delete the planned objects
+kln apply plan.json
+
+# Only delete the planned objects that did not change since
+kln apply plan.json --check-resource-version`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		plan, err := kln.ReadPlan(args[0])
+		exitOnError(err)
+		deleted, err := kln.ApplyPlan(kln.GetDynamicClient(kubeconfig), plan, checkResourceVersion)
+		kln.InfoLog.Printf("deleted %d of %d planned objects", deleted, len(plan.Objects))
+		exitOnError(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+	applyCmd.Flags().BoolVar(&checkResourceVersion, "check-resource-version", false, "skip the objects that changed since the plan was made")
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+
+	kln "github.com/adelmoradian/kln/pkg"
+	"github.com/spf13/cobra"
+)
+
+var grace string
+
+var cleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Flags objects and deletes them after a grace period",
+	Long: `Flags the objects that the resource identifiers select with a
+"kln.com/delete=true" label and a "kln.com/flagged-at" annotation, and
+deletes the flagged objects once they have been flagged for longer than
+--grace and still match. Objects that were flagged by clean but no longer
+match are unflagged. Run it periodically, for example from a CronJob, to
+give teams the grace period to object to a deletion, either by changing
+the object or by protecting it with "kln.com/protect=true".`,
+	Example: `# Flag new matches and delete the ones flagged more than a day ago
+kln clean
+
+# Wait three days before deleting
+kln clean --grace 3d
+
+# Delete the dependents of the objects before the objects themselves
+kln clean --cascade=foreground
+
+# Report what would be flagged, unflagged and deleted
+kln clean --dry-run=client`,
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun := parseDryRun()
+		policy := parseDeletePolicy()
+		age, err := kln.ParseAge(grace)
+		if err == nil && age <= 0 {
+			err = fmt.Errorf("grace period must be positive, got %q", grace)
+		}
+		exitOnError(err)
+		loadResourceIdentifiers()
+		dynamicClient := kln.GetDynamicClient(kubeconfig)
+
+		gvrs, ris := kln.GroupByGVR(riList.Items)
+		var changes []kln.Change
+		for _, gvr := range gvrs {
+			gvrChanges, err := kln.Clean(dynamicClient, gvr, ris[gvr], age.Duration(), policy, dryRun)
+			changes = append(changes, gvrChanges...)
+			if err != nil {
+				kln.ErrorLog.Printf("%s: %s", gvr.GroupResource(), err)
+			}
+		}
+		printChanges(changes, dryRun)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cleanCmd)
+	cleanCmd.Flags().StringVar(&grace, "grace", "24h", `how long an object stays flagged before it is deleted, such as "90m" or "3d"`)
+	addDeletePolicyFlags(cleanCmd)
+	addDryRunFlag(cleanCmd)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"os"
+
+	kln "github.com/adelmoradian/kln/pkg"
+	"github.com/spf13/cobra"
+)
+
+var crdCmd = &cobra.Command{
+	Use:   "crd",
+	Short: "Prints the CleanupPolicy CRDs",
+	Long: `Prints the manifests of the CleanupPolicy and ClusterCleanupPolicy
+custom resource definitions. The spec of both is a resource identifier,
+so the policies of the resource identifier yaml file can be stored in the
+cluster and read with --from-cluster. A CleanupPolicy only selects objects
+in its own namespace, a ClusterCleanupPolicy can select objects anywhere.`,
+	Example: `# Install the CRDs
+kln crd | kubectl apply -f -
+
+# List unwanted objects according to the policies in the cluster
+kln list --from-cluster`,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(kln.WriteCustomResourceDefinitions(os.Stdout))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(crdCmd)
+}
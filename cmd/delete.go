@@ -1,48 +1,105 @@
 package cmd
 
 import (
+	"os"
+
 	kln "github.com/adelmoradian/kln/pkg"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
-var propogationPolicy string
+var trustFlags bool
+var allResources bool
 
 // deleteCmd represents the delete command
 var deleteCmd = &cobra.Command{
 	Use:   "delete",
 	Short: "Deletes the flagged resources",
 	Long: `Deletes the the resources that have a "kln.com/delete=true" label.
-IMPORTANT -> In order for this to work properly, a resource identifier
-must be provided with a valid gvr (Group, Version Resource). Delete
-command does search all the api objects available in the cluster for
-"kln.com/delete=true" label. Instead it only searchs the provided gvr(s).
-Delete command gets the gvr(s) from the resource identifier yaml file.
-However it only cares about the gvr(s) and not any other criteria that
-may be available. For example assume that you run a cron job that flags
-completed jobs older 100 hours. Then if you run the delete command with a
-resources identifier that has "batch/v1 jobs" and "apps/v1 deployments"
-gvrs with some other criteria, the delete command will only search deployments
-and jobs for objects that are flagged for deletion and will delete them.
-It will NOT flag and delete any new objects from the new criteria. Obviously
-the "kln.com/delete" label can be manually changed as well. Currently kln
-does not record anything about the objects which it flags or deletes.`,
+IMPORTANT -> Delete command does not search all the api objects available
+in the cluster for the "kln.com/delete=true" label. Instead it only searches
+the resources of the resource identifier yaml file. For example if you run
+the delete command with a resource identifier file that has "batch/v1 jobs"
+and "apps/v1 deployments" gvrs, the delete command will only search
+deployments and jobs for objects that are flagged for deletion. It will NOT
+flag and delete any new objects. To search more resources, use a wildcard
+resource identifier, such as "group: tekton.dev" with "resource: '*'", or
+"allNamespacedResources: true". With --all-resources, every resource that
+the cluster serves and that can be listed and deleted is searched, and the
+deletions are reported per resource. The flags of resources that no
+resource identifier targets cannot be verified, so add --trust-flags to
+collect the objects that were flagged by hand or by an older file.
+
+kln flag records the resource identifier that flagged an object in the
+"kln.com/flagged-by" annotation. Before an object is deleted, that resource
+identifier is evaluated again and the object is skipped when it no longer
+matches, for example because a Job was run again since it was flagged.
+Objects that were flagged by hand, or by a resource identifier that is not
+in the file, are skipped too unless --trust-flags is given. The deletion is
+sent with the resourceVersion of the object as a precondition, so an object
+that changes after it was checked is not deleted either.
+
+The objects are deleted with --cascade and --grace-period, unless the
+deletePolicy of the resource identifier that flagged them overrides them.`,
+	Example: `# Delete the flagged objects of the resources in ./kln.yaml
+kln delete
+
+# Delete the flagged objects of every namespaced resource, whatever
+# flagged them, with a resource identifier like
+#   items:
+#     - allNamespacedResources: true
+#       labelSelector: kln.com/delete=true
+kln delete -f all.yaml --trust-flags
+
+# Delete PipelineRuns in the foreground, so their TaskRuns and Pods go
+# first, with a resource identifier like
+#   items:
+#     - apiVersion: tekton.dev/v1
+#       kind: PipelineRun
+#       minAge: 3d
+#       deletePolicy:
+#         cascade: foreground
+kln delete -f pipelineruns.yaml
+
+# Delete the dependents first and give the objects 30 seconds to terminate
+kln delete --cascade=foreground --grace-period=30
+
+# Delete the objects that were flagged by hand or by an older file, in
+# every resource of the cluster
+kln delete --all-resources --trust-flags
+
+# Report the deletions without making them
+kln delete --dry-run=client
+
+# Send the deletions with dryRun=All to exercise webhooks and RBAC
+kln delete --dry-run=server`,
 	Run: func(cmd *cobra.Command, args []string) {
+		dryRun := parseDryRun()
+		policy := parseDeletePolicy()
+		loadResourceIdentifiers()
 		dynamicClient := kln.GetDynamicClient(kubeconfig)
-		config := kln.ReadFile(file)
-		err := yaml.Unmarshal(config, &riList)
-		if err != nil {
-			panic(err)
+		if allResources {
+			changes, err := kln.DeleteAllResources(dynamicClient, kln.GetDiscoveryClient(kubeconfig), riList.Items, policy, trustFlags, dryRun)
+			exitOnError(err)
+			exitOnError(kln.PrintChangesByGVR(os.Stdout, changes, dryRun))
+			return
 		}
-		for _, ri := range riList.Items {
-			err := kln.DeleteResources(dynamicClient, ri.GVR)
+		gvrs, ris := kln.GroupByGVR(riList.Items)
+		var changes []kln.Change
+		for _, gvr := range gvrs {
+			gvrChanges, err := kln.DeleteResources(dynamicClient, gvr, ris[gvr], policy, trustFlags, dryRun)
+			changes = append(changes, gvrChanges...)
 			if err != nil {
-				kln.ErrorLog.Println(err)
+				kln.ErrorLog.Printf("%s: %s", gvr.GroupResource(), err)
 			}
 		}
+		printChanges(changes, dryRun)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(deleteCmd)
+	deleteCmd.Flags().BoolVar(&allResources, "all-resources", false, "search every resource that the cluster serves for flagged objects, not only the resources of the file")
+	deleteCmd.Flags().BoolVar(&trustFlags, "trust-flags", false, "delete flagged objects without checking that the resource identifier that flagged them still selects them")
+	addDeletePolicyFlags(deleteCmd)
+	addDryRunFlag(deleteCmd)
 }
@@ -3,7 +3,6 @@ package cmd
 import (
 	kln "github.com/adelmoradian/kln/pkg"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
 var cleanSwitch bool
@@ -12,31 +11,40 @@ var flagCmd = &cobra.Command{
 	Use:   "flag",
 	Short: "Flags objects for deletion",
 	Long: `Flags objects for deletion by adding a "kln/com/delete: true"
-label. By providing the undo flag, it "undo" the flagging by
+label, and a "kln.com/flagged-by" annotation with the name of the resource
+identifier that selected them, which delete uses to check that they still
+match. By providing the undo flag, it "undo" the flagging by
 changing the label from true to to false`,
 	Example: `# Flag for deletion by patching label "kln.com/delete=true"
 kln flag
 
 # Undo the deletion flag by patching label "kln.com/delete=false"
 kln flag -d=false
+
+# Report the label changes without making them
+kln flag --dry-run=client
+
+# Send the patches with dryRun=All to exercise webhooks and RBAC
+kln flag --dry-run=server
 `,
 	Run: func(cmd *cobra.Command, args []string) {
+		dryRun := parseDryRun()
+		loadResourceIdentifiers()
 		dynamicClient := kln.GetDynamicClient(kubeconfig)
-		config := kln.ReadFile(file)
-		err := yaml.Unmarshal(config, &riList)
-		if err != nil {
-			panic(err)
-		}
+		var changes []kln.Change
 		for _, ri := range riList.Items {
-			err := kln.FlagForDeletion(dynamicClient, ri, cleanSwitch)
+			riChanges, err := kln.FlagForDeletion(dynamicClient, ri, cleanSwitch, dryRun)
+			changes = append(changes, riChanges...)
 			if err != nil {
-				kln.ErrorLog.Println(err)
+				kln.ErrorLog.Printf("%s: resource identifier %q: %s", ri.Source, ri.Name, err)
 			}
 		}
+		printChanges(changes, dryRun)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(flagCmd)
 	flagCmd.Flags().BoolVarP(&cleanSwitch, "delete", "d", true, "When false, will label kln.com/delete: false")
+	addDryRunFlag(flagCmd)
 }
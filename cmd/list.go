@@ -1,40 +1,57 @@
 package cmd
 
 import (
+	"os"
+
 	kln "github.com/adelmoradian/kln/pkg"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
-type RiList struct {
-	Items []kln.ResourceIdentifier `yaml:"items"`
-}
-
-var riList RiList
+var output string
 
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List unwanted objects",
 	Long: `Lists unwanted objects according to the criteria given
-in the resource identifier yaml file.`,
+in the resource identifier yaml file. The output format can be one of
+table, wide, json, yaml or name. The json and yaml formats print a v1
+List document which can be piped into jq or "kubectl delete -f -".`,
 	Example: `# List unwated objects
 kln list
 
 # Provide path to resource identifier
-kln list -f ../rltv/path/to/identifier.yaml`,
+kln list -f ../rltv/path/to/identifier.yaml
+
+# Show the criteria that each object matched
+kln list -o wide
+
+# Delete the listed objects with kubectl
+kln list -o json | kubectl delete -f -`,
 	Run: func(cmd *cobra.Command, args []string) {
-		client := kln.GetDynamicClient(kubeconfig)
-		config := kln.ReadFile(file)
-		err := yaml.Unmarshal(config, &riList)
-		if err != nil {
-			panic(err)
+		if err := kln.ValidateOutputFormat(output); err != nil {
+			kln.ErrorLog.Fatalln(err)
 		}
+		loadResourceIdentifiers()
+		client := kln.GetDynamicClient(kubeconfig)
+		var matches []kln.Match
 		for _, ri := range riList.Items {
-			kln.ListResources(client, ri)
+			resources, err := kln.ListResources(client, ri)
+			if err != nil {
+				kln.ErrorLog.Printf("%s: resource identifier %q: %s", ri.Source, ri.Name, err)
+				continue
+			}
+			for _, resource := range resources {
+				matches = append(matches, kln.Match{RI: ri, Object: resource})
+			}
+		}
+		err := kln.PrintMatches(os.Stdout, output, matches)
+		if err != nil {
+			kln.ErrorLog.Println(err)
 		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().StringVarP(&output, "output", "o", kln.OutputTable, "output format, one of table|wide|json|yaml|name")
 }
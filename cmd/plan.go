@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"os"
+
+	kln "github.com/adelmoradian/kln/pkg"
+	"github.com/spf13/cobra"
+)
+
+var planFile string
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Records the objects that would be deleted",
+	Long: `Records every object that the resource identifiers select in a
+plan, along with its UID, its resourceVersion and the resource identifier
+that selected it. The plan can be reviewed and approved, and then deleted
+with "kln apply". Nothing is changed in the cluster.`,
+	Example: `# Write the plan to plan.json
+kln plan -o plan.json
+
+# Review it, then delete exactly the planned objects
+kln apply plan.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		loadResourceIdentifiers()
+		client := kln.GetDynamicClient(kubeconfig)
+		var matches []kln.Match
+		for _, ri := range riList.Items {
+			resources, err := kln.ListResources(client, ri)
+			if err != nil {
+				kln.ErrorLog.Printf("%s: resource identifier %q: %s", ri.Source, ri.Name, err)
+				continue
+			}
+			for _, resource := range resources {
+				matches = append(matches, kln.Match{RI: ri, Object: resource})
+			}
+		}
+		plan := kln.NewPlan(matches)
+
+		out := os.Stdout
+		if planFile != "-" {
+			var err error
+			out, err = os.Create(planFile)
+			exitOnError(err)
+			defer out.Close()
+		}
+		exitOnError(kln.WritePlan(out, plan))
+		kln.InfoLog.Printf("planned the deletion of %d objects", len(plan.Objects))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+	planCmd.Flags().StringVarP(&planFile, "output", "o", "-", "file to write the plan to, - for stdout")
+}
@@ -1,15 +1,22 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 
+	kln "github.com/adelmoradian/kln/pkg"
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/util/homedir"
 )
 
 var kubeconfig string
-var file string
+var files []string
+var fromCluster bool
+var riList *kln.Config
+var dryRunFlag string
+var cascade string
+var gracePeriod int64
 
 var rootCmd = &cobra.Command{
 	Use:   "kln",
@@ -22,6 +29,15 @@ kln list
 # Provide path to resource identifier
 kln list -f ../rltv/path/to/identifier.yaml
 
+# Merge the resource identifiers of several files and directories
+kln list -f team-a/ -f team-b/ -f shared.yaml
+
+# Read the resource identifiers from stdin
+kubectl get configmap kln -o jsonpath='{.data.kln\.yaml}' | kln list -f -
+
+# Expand ${MIN_AGE} and ${MIN_AGE:-1d} references in the files
+MIN_AGE=3d kln list
+
 # Flag for deletion by patching label "kln.com/delete=true"
 kln flag
 
@@ -29,7 +45,16 @@ kln flag
 kln flag -d=false
 
 # Delete resources that have "kln.com/delete=true" label
-kln delete`,
+kln delete
+
+# Flag objects and delete them when they are still flagged a day later
+kln clean --grace 1d
+
+# Check the resource identifier file without changing anything
+kln validate
+
+# Use the CleanupPolicies stored in the cluster, see "kln crd"
+kln list --from-cluster`,
 }
 
 func Execute() {
@@ -41,5 +66,73 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&kubeconfig, "kube-config", "k", filepath.Join(homedir.HomeDir(), ".kube", "config"), "abs path to the kubeconfig file")
-	rootCmd.PersistentFlags().StringVarP(&file, "file", "f", "./kln.yaml", "relative path to resource identifier yaml file")
+	rootCmd.PersistentFlags().StringArrayVarP(&files, "file", "f", []string{"./kln.yaml"}, "resource identifier yaml file, directory of them or - for stdin, can be repeated")
+	rootCmd.PersistentFlags().BoolVar(&fromCluster, "from-cluster", false, "read the resource identifiers from the CleanupPolicies in the cluster instead of the file")
+}
+
+// loadResourceIdentifiers reads and validates the resource identifier file,
+// or the cleanup policies with --from-cluster, into riList, checks that the
+// cluster serves the resources and sets up the safety policy. Every command
+// calls it before it touches the cluster, so that a broken file never gets
+// half applied.
+func loadResourceIdentifiers() {
+	config, err := readConfig()
+	if err == nil {
+		err = config.Resolve(kln.GetDiscoveryClient(kubeconfig))
+	}
+	exitOnError(err)
+	riList = config
+	kln.Safety = config.SafetyPolicy()
+}
+
+// exitOnError prints the error, one problem per line, and exits
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func readConfig() (*kln.Config, error) {
+	if fromCluster {
+		return kln.LoadClusterConfig(kln.GetDynamicClient(kubeconfig))
+	}
+	return kln.LoadConfig(files...)
+}
+
+// addDryRunFlag adds the --dry-run flag to a command that changes objects
+func addDryRunFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&dryRunFlag, "dry-run", string(kln.DryRunNone), "none, client to only report the changes, or server to send them with dryRun=All")
+}
+
+// addDeletePolicyFlags adds the --cascade and --grace-period flags to a
+// command that deletes objects
+func addDeletePolicyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&cascade, "cascade", kln.CascadeBackground, "background, foreground to delete the dependents first, or orphan to leave them behind")
+	cmd.Flags().Int64Var(&gracePeriod, "grace-period", -1, "seconds given to the objects to terminate, -1 for their default")
+}
+
+// parseDeletePolicy parses --cascade and --grace-period and exits when they
+// are invalid
+func parseDeletePolicy() kln.DeletePolicy {
+	exitOnError(kln.ValidateCascade(cascade))
+	policy := kln.DeletePolicy{Cascade: cascade}
+	if gracePeriod >= 0 {
+		policy.GracePeriodSeconds = &gracePeriod
+	}
+	return policy
+}
+
+// parseDryRun parses --dry-run and exits when it is invalid
+func parseDryRun() kln.DryRun {
+	dryRun, err := kln.ParseDryRun(dryRunFlag)
+	exitOnError(err)
+	return dryRun
+}
+
+// printChanges reports the changes of a dry run
+func printChanges(changes []kln.Change, dryRun kln.DryRun) {
+	if dryRun.Enabled() {
+		exitOnError(kln.PrintChanges(os.Stdout, changes, dryRun))
+	}
 }
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	kln "github.com/adelmoradian/kln/pkg"
+	"github.com/spf13/cobra"
+)
+
+var offline bool
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validates the resource identifier file",
+	Long: `Validates the resource identifier file without listing, flagging
+or deleting anything. Unknown fields, values of the wrong type, negative
+ages, resource identifiers without a gvr or without any criteria and
+invalid expressions are reported as file:line:col errors. Unless offline,
+it also checks that the cluster serves the resource of every resource
+identifier and resolves the ones given by kind. The other commands run
+the same validation before they touch the cluster.`,
+	Example: `# Validate ./kln.yaml against the cluster
+kln validate
+
+# Validate a file without connecting to the cluster
+kln validate -f ../rltv/path/to/identifier.yaml --offline`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := readConfig()
+		if err == nil && !offline {
+			err = config.Resolve(kln.GetDiscoveryClient(kubeconfig))
+		}
+		exitOnError(err)
+		source := strings.Join(files, ",")
+		if fromCluster {
+			source = "cluster"
+		}
+		fmt.Printf("%s: %d resource identifiers are valid\n", source, len(config.Items))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+	validateCmd.Flags().BoolVar(&offline, "offline", false, "skip the checks that need the cluster")
+}
@@ -0,0 +1,107 @@
+package kln
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Age is a number of hours. In yaml it can be given either as a number of
+// hours or as a duration string such as "90m", "72h" or "3d".
+type Age float64
+
+func (a *Age) UnmarshalYAML(node *yaml.Node) error {
+	var hours float64
+	if err := node.Decode(&hours); err == nil {
+		*a = Age(hours)
+		return nil
+	}
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	age, err := ParseAge(s)
+	if err != nil {
+		return err
+	}
+	*a = age
+	return nil
+}
+
+// ParseAge parses a go duration string that may also contain a number of
+// days with the "d" suffix, for example "3d" or "1d12h".
+func ParseAge(s string) (Age, error) {
+	var days float64
+	rest := strings.TrimSpace(s)
+	if i := strings.Index(rest, "d"); i >= 0 {
+		var err error
+		days, err = strconv.ParseFloat(rest[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		rest = rest[i+1:]
+	}
+	var d time.Duration
+	if rest != "" {
+		var err error
+		d, err = time.ParseDuration(rest)
+		if err != nil || (days != 0 && d < 0) {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+	}
+	return Age(days*24 + d.Hours()), nil
+}
+
+func (a Age) Duration() time.Duration {
+	return time.Duration(float64(a) * float64(time.Hour))
+}
+
+// ageOf measures the age of the object from the timestamp at ageField, or
+// from its creationTimestamp when ageField is not set. Objects without a
+// valid timestamp cannot be measured and are skipped.
+func ageOf(item unstructured.Unstructured, ageField string, now time.Time) (time.Duration, error) {
+	timestamp, err := timestampOf(item, ageField)
+	if err != nil {
+		return 0, err
+	}
+	return now.Sub(timestamp), nil
+}
+
+// timestampOf returns the timestamp at field, or the creationTimestamp when
+// field is not set
+func timestampOf(item unstructured.Unstructured, field string) (time.Time, error) {
+	if field == "" {
+		return item.GetCreationTimestamp().Time, nil
+	}
+	path, err := parseFieldPath(field)
+	if err != nil {
+		return time.Time{}, err
+	}
+	value, found := path.lookup(item.Object)
+	if !found || value == nil {
+		return time.Time{}, skipError{fmt.Sprintf("%s is missing", field)}
+	}
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, skipError{fmt.Sprintf("%s is not a timestamp: %v", field, value)}
+	}
+	timestamp, err := time.Parse(RFC3339, s)
+	if err != nil {
+		return time.Time{}, skipError{fmt.Sprintf("%s is not a timestamp: %q", field, s)}
+	}
+	return timestamp, nil
+}
+
+// skipError marks an object that cannot be evaluated. The object is skipped
+// and reported instead of failing the whole resource identifier.
+type skipError struct {
+	reason string
+}
+
+func (e skipError) Error() string {
+	return e.reason
+}
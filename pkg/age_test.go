@@ -0,0 +1,90 @@
+package kln
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAgeUnmarshalYAML(t *testing.T) {
+	ageTests := []struct {
+		yaml      string
+		want      Age
+		wantError bool
+	}{
+		{yaml: `72`, want: 72},
+		{yaml: `0.5`, want: 0.5},
+		{yaml: `72h`, want: 72},
+		{yaml: `90m`, want: 1.5},
+		{yaml: `3d`, want: 72},
+		{yaml: `1d12h`, want: 36},
+		{yaml: `0.5d`, want: 12},
+		{yaml: `"72"`, wantError: true},
+		{yaml: `3 days`, wantError: true},
+		{yaml: `d`, wantError: true},
+		{yaml: `[72]`, wantError: true},
+	}
+
+	for _, tc := range ageTests {
+		t.Run(tc.yaml, func(t *testing.T) {
+			var got struct {
+				Age Age `yaml:"age"`
+			}
+			err := yaml.Unmarshal([]byte("age: "+tc.yaml), &got)
+			if tc.wantError != (err != nil) {
+				t.Errorf("got error %v, want error %v", err, tc.wantError)
+			}
+			if got.Age != tc.want {
+				t.Errorf("got %v, want %v", got.Age, tc.want)
+			}
+		})
+	}
+}
+
+func TestAgeOf(t *testing.T) {
+	now, _ := time.Parse(RFC3339, "2022-10-04T10:00:00Z")
+	var job unstructured.Unstructured
+	if err := job.UnmarshalJSON([]byte(completedJob)); err != nil {
+		t.Fatal(err)
+	}
+	job.SetCreationTimestamp(r1.DeepCopy().GetCreationTimestamp())
+	unstructured.SetNestedField(job.Object, "2022-10-01T09:00:00Z", "status", "completionTime")
+	unstructured.SetNestedField(job.Object, "yesterday", "status", "startTime")
+
+	ageOfTests := []struct {
+		name     string
+		ageField string
+		want     time.Duration
+		wantSkip string
+	}{
+		{name: "happy - completionTime", ageField: "status.completionTime", want: 73 * time.Hour},
+		{name: "happy - lastTransitionTime of a condition", ageField: "status.conditions[type=Complete].lastTransitionTime", want: 72 * time.Hour},
+		{name: "sad - missing field is skipped", ageField: "status.conditions[type=Failed].lastTransitionTime", wantSkip: "is missing"},
+		{name: "sad - unparsable field is skipped", ageField: "status.startTime", wantSkip: "is not a timestamp"},
+		{name: "sad - non string field is skipped", ageField: "status.succeeded", wantSkip: "is not a timestamp"},
+	}
+
+	for _, tc := range ageOfTests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ageOf(job, tc.ageField, now)
+			var skip skipError
+			if tc.wantSkip != "" && (!errors.As(err, &skip) || !strings.Contains(err.Error(), tc.wantSkip)) {
+				t.Errorf("expected the object to be skipped with %q but got %v", tc.wantSkip, err)
+			}
+			if tc.wantSkip == "" && (err != nil || got != tc.want) {
+				t.Errorf("got %v %v, want %v", got, err, tc.want)
+			}
+		})
+	}
+
+	t.Run("happy - defaults to creationTimestamp", func(t *testing.T) {
+		got, err := ageOf(job, "", time.Now())
+		if err != nil || got < 10*time.Minute || got > 11*time.Minute {
+			t.Errorf("got %v %v, want about 10m", got, err)
+		}
+	})
+}
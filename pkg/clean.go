@@ -2,25 +2,295 @@ package kln
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"time"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 )
 
-func DeleteResources(client dynamic.Interface, gvr schema.GroupVersionResource) error {
+// DeleteResources deletes the objects of the resource that are labeled
+// kln.com/delete=true, unless the safety policy protects them. Before an
+// object is deleted, the resource identifier that flagged it is evaluated
+// again, and the object is skipped when it no longer selects it, for example
+// because a Job was run again since it was flagged. Objects that were not
+// flagged by one of the resource identifiers cannot be verified and are only
+// deleted with trustFlags. The deletions are made with the delete policy,
+// overridden by that of the resource identifier, and with the UID and the
+// resourceVersion of the verified object as preconditions. It returns the
+// deletions, which are only reported in a client dry run.
+func DeleteResources(client dynamic.Interface, gvr schema.GroupVersionResource, ris []ResourceIdentifier, policy DeletePolicy, trustFlags bool, dryRun DryRun) ([]Change, error) {
 	items, err := client.Resource(gvr).List(context.TODO(), v1.ListOptions{LabelSelector: "kln.com/delete=true"})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	verifier := flagVerifier{client: client, ris: ris, matches: map[int]map[string]unstructured.Unstructured{}}
+	var changes []Change
 	for _, item := range items.Items {
 		name := item.GetName()
 		ns := item.GetNamespace()
-		err := client.Resource(gvr).Namespace(ns).Delete(context.TODO(), name, v1.DeleteOptions{})
+		if reason := Safety.Check(item); reason != "" {
+			WarningLog.Printf("skipping %s/%s: %s", ns, name, reason)
+			continue
+		}
+		match, reason, err := verifier.verify(item)
+		if err != nil {
+			return changes, err
+		}
+		change := Change{Match: match, Action: "delete"}
+		options := policy.Override(match.RI.DeletePolicy).deleteOptions(dryRun)
+		if reason != "" {
+			if !trustFlags {
+				WarningLog.Printf("skipping %s/%s: %s", ns, name, reason)
+				continue
+			}
+			change = Change{Match: Match{RI: ResourceIdentifier{GVR: gvr}, Object: item}, Action: "delete (unverified)"}
+			options = policy.deleteOptions(dryRun)
+		}
+		if dryRun == DryRunClient {
+			changes = append(changes, change)
+			continue
+		}
+		options.Preconditions = preconditions(change.Object)
+		err = client.Resource(gvr).Namespace(ns).Delete(context.TODO(), name, options)
+		if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+			WarningLog.Printf("skipping %s/%s: it changed since it was verified", ns, name)
+			continue
+		}
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// GroupByGVR groups the resource identifiers by the resource that they
+// target, in the order that the resources first appear
+func GroupByGVR(items []ResourceIdentifier) ([]schema.GroupVersionResource, map[schema.GroupVersionResource][]ResourceIdentifier) {
+	var gvrs []schema.GroupVersionResource
+	ris := map[schema.GroupVersionResource][]ResourceIdentifier{}
+	for _, ri := range items {
+		if _, ok := ris[ri.GVR]; !ok {
+			gvrs = append(gvrs, ri.GVR)
+		}
+		ris[ri.GVR] = append(ris[ri.GVR], ri)
+	}
+	return gvrs, ris
+}
+
+// DeleteAllResources deletes the flagged objects of every resource that the
+// cluster serves and that can be listed and deleted, as DeleteResources
+// does. Nothing in the configuration can verify the flags of the resources
+// that no resource identifier targets, which were set by hand or by an older
+// configuration, so those objects are only deleted with trustFlags. The
+// safety policy applies to all of them. A resource that cannot be searched
+// is reported and the others are still searched.
+func DeleteAllResources(client dynamic.Interface, discoveryClient discovery.DiscoveryInterface, items []ResourceIdentifier, policy DeletePolicy, trustFlags bool, dryRun DryRun) ([]Change, error) {
+	gvrs, ris := GroupByGVR(items)
+	discovered, err := DeletableResources(discoveryClient)
+	if err != nil {
+		return nil, err
+	}
+	configured := map[schema.GroupResource]bool{}
+	for _, gvr := range gvrs {
+		configured[gvr.GroupResource()] = true
+	}
+	for _, gvr := range discovered {
+		if !configured[gvr.GroupResource()] {
+			gvrs = append(gvrs, gvr)
+		}
+	}
+
+	var changes []Change
+	for _, gvr := range gvrs {
+		gvrChanges, err := DeleteResources(client, gvr, ris[gvr], policy, trustFlags, dryRun)
+		changes = append(changes, gvrChanges...)
+		if err != nil {
+			ErrorLog.Printf("%s: %s", gvr.GroupResource(), err)
+		}
+	}
+	return changes, nil
+}
+
+// flagVerifier evaluates the resource identifiers that flagged objects again,
+// each of them at most once
+type flagVerifier struct {
+	client  dynamic.Interface
+	ris     []ResourceIdentifier
+	matches map[int]map[string]unstructured.Unstructured
+}
+
+// verify returns the object as the first resource identifier named in its
+// kln.com/flagged-by annotation selects it now, or the reason why its flag
+// cannot be verified
+func (v *flagVerifier) verify(item unstructured.Unstructured) (Match, string, error) {
+	flaggedBy, ok := item.GetAnnotations()[FlaggedByAnnotation]
+	if !ok {
+		return Match{}, fmt.Sprintf("it has no %s annotation, so its flag cannot be verified", FlaggedByAnnotation), nil
+	}
+	found := false
+	for i, ri := range v.ris {
+		if ri.Name != flaggedBy {
+			continue
+		}
+		found = true
+		matches, err := v.matchesOf(i)
+		if err != nil {
+			return Match{}, "", err
+		}
+		if object, ok := matches[objectKey(item)]; ok {
+			return Match{RI: ri, Object: object}, "", nil
+		}
+	}
+	if !found {
+		return Match{}, fmt.Sprintf("resource identifier %q that flagged it is not in the configuration", flaggedBy), nil
+	}
+	return Match{}, fmt.Sprintf("it no longer matches resource identifier %q", flaggedBy), nil
+}
+
+func (v *flagVerifier) matchesOf(i int) (map[string]unstructured.Unstructured, error) {
+	if matches, ok := v.matches[i]; ok {
+		return matches, nil
+	}
+	resources, err := ListResources(v.client, v.ris[i])
+	if err != nil {
+		return nil, err
+	}
+	matches := map[string]unstructured.Unstructured{}
+	for _, resource := range resources {
+		matches[objectKey(resource)] = resource
+	}
+	v.matches[i] = matches
+	return matches, nil
+}
+
+// preconditions make sure that a deletion only deletes the object as it was
+// listed
+func preconditions(item unstructured.Unstructured) *v1.Preconditions {
+	uid, resourceVersion := item.GetUID(), item.GetResourceVersion()
+	return &v1.Preconditions{UID: &uid, ResourceVersion: &resourceVersion}
+}
+
+// FlaggedAtAnnotation records when kln clean flagged an object, so that a
+// later run only deletes it once the grace period has passed
+const FlaggedAtAnnotation = "kln.com/flagged-at"
+
+// Clean flags, deletes and unflags the objects of one resource. The resource
+// identifiers must all target gvr. Objects that one of them selects are
+// flagged with kln.com/delete=true and the time of flagging, and deleted by a
+// later run once they have been flagged for longer than grace. Objects that
+// were flagged by Clean but are no longer selected are unflagged, so teams
+// can rescue an object by changing it within the grace period. Objects are
+// deleted with the delete policy, overridden by that of the resource
+// identifier that selected them.
+func Clean(client dynamic.Interface, gvr schema.GroupVersionResource, ris []ResourceIdentifier, grace time.Duration, policy DeletePolicy, dryRun DryRun) ([]Change, error) {
+	now := time.Now()
+	var matches []Match
+	matched := map[string]bool{}
+	flagged := map[string]unstructured.Unstructured{}
+	for _, ri := range ris {
+		if ri.GVR != gvr {
+			return nil, fmt.Errorf("resource identifier %q does not target %s", ri.Name, formatGVR(gvr))
+		}
+		resources, err := ListResources(client, ri)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range resources {
+			if key := objectKey(resource); !matched[key] {
+				matched[key] = true
+				matches = append(matches, Match{RI: ri, Object: resource})
+			}
+		}
+		items, err := ri.listInScope(client, v1.ListOptions{LabelSelector: "kln.com/delete=true"})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if _, ok := item.GetAnnotations()[FlaggedAtAnnotation]; ok {
+				flagged[objectKey(item)] = item
+			}
+		}
+	}
+
+	var changes []Change
+	for _, match := range matches {
+		flaggedAt, ok := match.Object.GetAnnotations()[FlaggedAtAnnotation]
+		if !ok || match.Object.GetLabels()["kln.com/delete"] != "true" {
+			patch := flagPatch(true, map[string]interface{}{
+				FlaggedAtAnnotation: now.UTC().Format(RFC3339),
+				FlaggedByAnnotation: match.RI.Name,
+			})
+			changes = append(changes, Change{Match: match, Action: "flag"})
+			if err := patchObject(client, match, patch, dryRun); err != nil {
+				return changes, err
+			}
+			continue
+		}
+		timestamp, err := time.Parse(RFC3339, flaggedAt)
 		if err != nil {
-			return err
+			WarningLog.Printf("skipping %s/%s: %s is not a timestamp: %q", match.Object.GetNamespace(), match.Object.GetName(), FlaggedAtAnnotation, flaggedAt)
+			continue
+		}
+		if now.Sub(timestamp) < grace {
+			continue
+		}
+		changes = append(changes, Change{Match: match, Action: "delete"})
+		if dryRun == DryRunClient {
+			continue
+		}
+		options := policy.Override(match.RI.DeletePolicy).deleteOptions(dryRun)
+		options.Preconditions = preconditions(match.Object)
+		err = client.Resource(gvr).Namespace(match.Object.GetNamespace()).Delete(context.TODO(), match.Object.GetName(), options)
+		if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+			WarningLog.Printf("skipping %s/%s: it changed since it was listed", match.Object.GetNamespace(), match.Object.GetName())
+			changes = changes[:len(changes)-1]
+			continue
+		}
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	var stale []unstructured.Unstructured
+	for key, item := range flagged {
+		if !matched[key] && Safety.Check(item) == "" {
+			stale = append(stale, item)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		if stale[i].GetNamespace() != stale[j].GetNamespace() {
+			return stale[i].GetNamespace() < stale[j].GetNamespace()
 		}
+		return stale[i].GetName() < stale[j].GetName()
+	})
+	for _, item := range stale {
+		match := Match{RI: ResourceIdentifier{GVR: gvr}, Object: item}
+		patch := flagPatch(false, map[string]interface{}{FlaggedAtAnnotation: nil, FlaggedByAnnotation: nil})
+		changes = append(changes, Change{Match: match, Action: "unflag"})
+		if err := patchObject(client, match, patch, dryRun); err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+func objectKey(item unstructured.Unstructured) string {
+	return item.GetNamespace() + "/" + item.GetName()
+}
+
+func patchObject(client dynamic.Interface, match Match, patch []byte, dryRun DryRun) error {
+	if dryRun == DryRunClient {
+		return nil
 	}
-	return nil
+	_, err := client.Resource(match.RI.GVR).Namespace(match.Object.GetNamespace()).Patch(context.TODO(), match.Object.GetName(), types.MergePatchType, patch, v1.PatchOptions{DryRun: dryRun.options()})
+	return err
 }
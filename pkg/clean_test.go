@@ -2,14 +2,21 @@ package kln
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	discoveryfake "k8s.io/client-go/discovery/fake"
 	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
 )
 
 func TestReal(t *testing.T) {
@@ -36,7 +43,7 @@ func TestReal(t *testing.T) {
 	client.Resource(ri.GVR).Namespace("ns").Patch(context.TODO(), "name1", types.MergePatchType, patchTrue, v1.PatchOptions{})
 	response2, _ := client.Resource(ri.GVR).Namespace("ns").Patch(context.TODO(), "name2", types.MergePatchType, patchFalse, v1.PatchOptions{})
 	t.Run("happy - deletes only the resource which is labeled", func(t *testing.T) {
-		err := DeleteResources(client, ri.GVR)
+		_, err := DeleteResources(client, ri.GVR, nil, DeletePolicy{}, true, DryRunNone)
 		if err != nil {
 			t.Errorf("got err %s", err)
 		}
@@ -54,3 +61,252 @@ func TestReal(t *testing.T) {
 	})
 
 }
+
+func TestClean(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(schema.GroupVersionKind{Group: aGVRK.GVR.Group, Version: aGVRK.GVR.Version, Kind: aGVRK.Kind + "List"}, &unstructured.Unstructured{})
+	client := dynamicfake.NewSimpleDynamicClient(scheme)
+	stale := r1.DeepCopy()
+	stale.SetLabels(map[string]string{"kln.com/delete": "true"})
+	stale.SetAnnotations(map[string]string{FlaggedAtAnnotation: time.Now().Add(-48 * time.Hour).Format(RFC3339)})
+	for _, item := range []*unstructured.Unstructured{stale, r2.DeepCopy()} {
+		if _, err := client.Resource(aGVRK.GVR).Namespace(item.GetNamespace()).Create(context.TODO(), item, v1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ris := []ResourceIdentifier{{Name: "old", GVR: aGVRK.GVR, Criteria: Criteria{MinAge: 0.5}}}
+	actions := func(changes []Change) map[string]string {
+		got := map[string]string{}
+		for _, change := range changes {
+			got[change.Object.GetName()] = change.Action
+		}
+		return got
+	}
+	get := func(name string) *unstructured.Unstructured {
+		object, err := client.Resource(aGVRK.GVR).Namespace("ns").Get(context.TODO(), name, v1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return object
+	}
+
+	t.Run("flags new matches and unflags objects that stopped matching", func(t *testing.T) {
+		changes, err := Clean(client, aGVRK.GVR, ris, time.Hour, DeletePolicy{}, DryRunNone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{"name1": "unflag", "name2": "flag"}
+		if got := actions(changes); !reflect.DeepEqual(got, want) {
+			t.Errorf("want %v but got %v", want, got)
+		}
+		name1, name2 := get("name1"), get("name2")
+		if _, ok := name1.GetAnnotations()[FlaggedAtAnnotation]; ok || name1.GetLabels()["kln.com/delete"] != "false" {
+			t.Errorf("name1 is still flagged: %v %v", name1.GetLabels(), name1.GetAnnotations())
+		}
+		if _, ok := name2.GetAnnotations()[FlaggedAtAnnotation]; !ok || name2.GetLabels()["kln.com/delete"] != "true" {
+			t.Errorf("name2 is not flagged: %v %v", name2.GetLabels(), name2.GetAnnotations())
+		}
+	})
+
+	t.Run("does not delete within the grace period", func(t *testing.T) {
+		changes, err := Clean(client, aGVRK.GVR, ris, time.Hour, DeletePolicy{}, DryRunNone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 0 {
+			t.Errorf("want no changes but got %v", actions(changes))
+		}
+		get("name2")
+	})
+
+	t.Run("deletes objects flagged for longer than the grace period", func(t *testing.T) {
+		name2 := get("name2")
+		name2.SetAnnotations(map[string]string{FlaggedAtAnnotation: time.Now().Add(-2 * time.Hour).Format(RFC3339)})
+		if _, err := client.Resource(aGVRK.GVR).Namespace("ns").Update(context.TODO(), name2, v1.UpdateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		changes, err := Clean(client, aGVRK.GVR, ris, time.Hour, DeletePolicy{}, DryRunNone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{"name2": "delete"}
+		if got := actions(changes); !reflect.DeepEqual(got, want) {
+			t.Errorf("want %v but got %v", want, got)
+		}
+		if _, err := client.Resource(aGVRK.GVR).Namespace("ns").Get(context.TODO(), "name2", v1.GetOptions{}); err == nil {
+			t.Error("name2 was not deleted")
+		}
+		get("name1")
+	})
+
+	t.Run("rejects resource identifiers of another resource", func(t *testing.T) {
+		_, err := Clean(client, aGVRK.GVR, []ResourceIdentifier{{Name: "fake", GVR: fakeGVRK.GVR}}, time.Hour, DeletePolicy{}, DryRunNone)
+		if err == nil {
+			t.Error("want an error but got none")
+		}
+	})
+}
+
+func TestDeletePolicy(t *testing.T) {
+	seconds := int64(30)
+	t.Run("resource identifier overrides the command line", func(t *testing.T) {
+		policy := DeletePolicy{Cascade: CascadeBackground}.Override(&DeletePolicy{Cascade: CascadeForeground, GracePeriodSeconds: &seconds})
+		options := policy.deleteOptions(DryRunServer)
+		if options.PropagationPolicy == nil || *options.PropagationPolicy != v1.DeletePropagationForeground {
+			t.Errorf("want foreground propagation but got %v", options.PropagationPolicy)
+		}
+		if options.GracePeriodSeconds == nil || *options.GracePeriodSeconds != seconds {
+			t.Errorf("want a grace period of %d but got %v", seconds, options.GracePeriodSeconds)
+		}
+		if !reflect.DeepEqual(options.DryRun, []string{v1.DryRunAll}) {
+			t.Errorf("want dryRun All but got %v", options.DryRun)
+		}
+	})
+
+	t.Run("unset fields keep the command line", func(t *testing.T) {
+		policy := DeletePolicy{Cascade: CascadeOrphan, GracePeriodSeconds: &seconds}.Override(&DeletePolicy{})
+		if policy.Cascade != CascadeOrphan || policy.GracePeriodSeconds != &seconds {
+			t.Errorf("want the command line policy but got %+v", policy)
+		}
+		if options := (DeletePolicy{}).deleteOptions(DryRunNone); options.PropagationPolicy != nil || options.GracePeriodSeconds != nil {
+			t.Errorf("want the server defaults but got %+v", options)
+		}
+	})
+
+	t.Run("invalid policies", func(t *testing.T) {
+		negative := int64(-1)
+		for _, policy := range []DeletePolicy{{Cascade: "cascading"}, {GracePeriodSeconds: &negative}} {
+			if err := policy.validate(); err == nil {
+				t.Errorf("want an error for %+v but got none", policy)
+			}
+		}
+	})
+}
+
+func TestDeleteVerifiesFlags(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(schema.GroupVersionKind{Group: aGVRK.GVR.Group, Version: aGVRK.GVR.Version, Kind: aGVRK.Kind + "List"}, &unstructured.Unstructured{})
+	client := dynamicfake.NewSimpleDynamicClient(scheme)
+	for _, item := range []*unstructured.Unstructured{r1.DeepCopy(), r2.DeepCopy(), r3.DeepCopy()} {
+		if _, err := client.Resource(aGVRK.GVR).Namespace(item.GetNamespace()).Create(context.TODO(), item, v1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := FlagForDeletion(client, ResourceIdentifier{Name: "old", GVR: aGVRK.GVR, Criteria: Criteria{MinAge: 0.5}}, true, DryRunNone); err != nil {
+		t.Fatal(err)
+	}
+	client.Resource(aGVRK.GVR).Namespace("ns").Patch(context.TODO(), "name1", types.MergePatchType, []byte(`{"metadata":{"labels":{"kln.com/delete":"true"}}}`), v1.PatchOptions{})
+	name2, _ := client.Resource(aGVRK.GVR).Namespace("ns").Get(context.TODO(), "name2", v1.GetOptions{})
+	if got := name2.GetAnnotations()[FlaggedByAnnotation]; got != "old" {
+		t.Errorf("want name2 to be flagged by old but got %q", got)
+	}
+	remaining := func() string {
+		list, _ := client.Resource(aGVRK.GVR).List(context.TODO(), v1.ListOptions{})
+		var names []string
+		for _, item := range list.Items {
+			names = append(names, item.GetName())
+		}
+		sort.Strings(names)
+		return strings.Join(names, ",")
+	}
+	// name2 is 40 minutes old and no longer matches once "old" means an hour
+	ris := []ResourceIdentifier{{Name: "old", GVR: aGVRK.GVR, Criteria: Criteria{MinAge: 1}}}
+
+	t.Run("happy - deletes only the objects that still match", func(t *testing.T) {
+		changes, err := DeleteResources(client, aGVRK.GVR, ris, DeletePolicy{}, false, DryRunNone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 1 || changes[0].Object.GetName() != "name3" || changes[0].RI.Name != "old" {
+			t.Errorf("want name3 to be deleted but got %v", changes)
+		}
+		if got := remaining(); got != "name1,name2" {
+			t.Errorf("got %s left, want name1,name2", got)
+		}
+	})
+
+	t.Run("happy - trusts the flags when asked to", func(t *testing.T) {
+		changes, err := DeleteResources(client, aGVRK.GVR, ris, DeletePolicy{}, true, DryRunNone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 2 {
+			t.Fatalf("want two unverified deletions but got %v", changes)
+		}
+		for _, change := range changes {
+			if change.Action != "delete (unverified)" || change.RI.Name != "" || change.RI.GVR != aGVRK.GVR {
+				t.Errorf("want an unverified deletion without an identifier but got %q by %q", change.Action, change.RI.Name)
+			}
+		}
+		if got := remaining(); got != "" {
+			t.Errorf("got %s left, want nothing", got)
+		}
+	})
+}
+
+func TestDeleteAllResources(t *testing.T) {
+	defer func(safety SafetyPolicy) { Safety = safety }(Safety)
+	Safety = SafetyPolicy{ProtectedNamespaces: []string{"prod"}}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		aGVRK.GVR:    aGVRK.Kind + "List",
+		fakeGVRK.GVR: fakeGVRK.Kind + "List",
+	})
+	discoveryClient := &discoveryfake.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*v1.APIResourceList{
+		{GroupVersion: aGVRK.GVR.GroupVersion().String(), APIResources: []v1.APIResource{{Name: aGVRK.GVR.Resource, Kind: aGVRK.Kind, Namespaced: true, Verbs: v1.Verbs{"delete", "list"}}}},
+		{GroupVersion: fakeGVRK.GVR.GroupVersion().String(), APIResources: []v1.APIResource{{Name: fakeGVRK.GVR.Resource, Kind: fakeGVRK.Kind, Namespaced: true, Verbs: v1.Verbs{"delete", "list"}}}},
+	}}}
+	create := func(gvrk GVRK, namespace, name string, annotations map[string]string) {
+		item := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": gvrk.GVR.GroupVersion().String(), "kind": gvrk.Kind}}
+		item.SetNamespace(namespace)
+		item.SetName(name)
+		item.SetCreationTimestamp(v1.NewTime(time.Now().Add(-2 * time.Hour)))
+		item.SetLabels(map[string]string{"kln.com/delete": "true"})
+		item.SetAnnotations(annotations)
+		if _, err := client.Resource(gvrk.GVR).Namespace(namespace).Create(context.TODO(), item, v1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	create(aGVRK, "ns", "verified", map[string]string{FlaggedByAnnotation: "old"})
+	create(aGVRK, "ns", "by-hand", nil)
+	create(fakeGVRK, "ns", "stranded", nil)
+	create(fakeGVRK, "prod", "protected", nil)
+	ris := []ResourceIdentifier{{Name: "old", GVR: aGVRK.GVR, Criteria: Criteria{MinAge: 1}}}
+
+	deleteTests := []struct {
+		name       string
+		trustFlags bool
+		want       []string
+	}{
+		{
+			name: "happy - flags that cannot be verified are skipped",
+			want: []string{"akinds.aversion.agroup ns/verified old delete"},
+		},
+		{
+			name:       "happy - flags that cannot be verified are trusted when asked to",
+			trustFlags: true,
+			want: []string{
+				"akinds.aversion.agroup ns/verified old delete",
+				"akinds.aversion.agroup ns/by-hand <none> delete (unverified)",
+				"fakes.fakeVersion.fake ns/stranded <none> delete (unverified)",
+			},
+		},
+	}
+
+	for _, tc := range deleteTests {
+		t.Run(tc.name, func(t *testing.T) {
+			changes, err := DeleteAllResources(client, discoveryClient, ris, DeletePolicy{}, tc.trustFlags, DryRunClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, change := range changes {
+				got = append(got, fmt.Sprintf("%s %s/%s %s %s", formatGVR(change.RI.GVR), change.Object.GetNamespace(), change.Object.GetName(), orNone(change.RI.Name), change.Action))
+			}
+			sort.Strings(got)
+			sort.Strings(tc.want)
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+}
@@ -1,88 +1,66 @@
 package kln
 
 import (
-	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
 
-	"k8s.io/apimachinery/pkg/api/equality"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"github.com/google/cel-go/cel"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-var InfoLog = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-var WarningLog = log.New(os.Stdout, "WARNING: ", log.Ldate|log.Ltime|log.Lshortfile)
-var ErrorLog = log.New(os.Stdout, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+var InfoLog = log.New(os.Stderr, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
+var WarningLog = log.New(os.Stderr, "WARNING: ", log.Ldate|log.Ltime|log.Lshortfile)
+var ErrorLog = log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
 
 const (
 	RFC3339 = "2006-01-02T15:04:05Z07:00"
 )
 
+// ResourceIdentifier selects the objects of one resource. The resource is
+// either given by its gvr, or by its kind along with an apiVersion or a
+// group, in which case Config.Resolve looks the gvr up. Resource "*" along
+// with a group or an apiVersion, and AllNamespacedResources, are wildcards
+// that Config.Resolve expands to every resource that they match.
 type ResourceIdentifier struct {
-	GVR         schema.GroupVersionResource `yaml:"gvr"`
-	MinAge      float64                     `yaml:"minAge"`
-	Metadata    map[string]interface{}      `yaml:"metadata"`
-	Spec        map[string]interface{}      `yaml:"spec"`
-	Status      map[string]interface{}      `yaml:"status"`
-	Name        string                      `yaml:"name"`
-	Description string                      `yaml:"description"`
-}
+	GVR                    schema.GroupVersionResource `yaml:"gvr"`
+	APIVersion             string                      `yaml:"apiVersion"`
+	Group                  string                      `yaml:"group"`
+	Kind                   string                      `yaml:"kind"`
+	Resource               string                      `yaml:"resource"`
+	AllNamespacedResources bool                        `yaml:"allNamespacedResources"`
+	Name                   string                      `yaml:"name"`
+	Description            string                      `yaml:"description"`
+	Expression             string                      `yaml:"expression"`
 
-func mapIntersection(mapA, mapB map[string]interface{}) bool {
-	for k, vA := range mapA {
-		if vB, ok := mapB[k]; ok && typeof(vA) == typeof(vB) {
-			if typeof(vA) == "map[string]interface {}" {
-				return mapIntersection(vA.(map[string]interface{}), vB.(map[string]interface{}))
-			}
-			if typeof(vA) == "[]interface {}" {
-				return arrayIntersection(vA.([]interface{}), vB.([]interface{}))
-			}
-			if vA != vB {
-				return false
-			}
-		} else {
-			return false
-		}
-	}
-	return true
-}
+	LabelSelector     string   `yaml:"labelSelector"`
+	FieldSelector     string   `yaml:"fieldSelector"`
+	Namespaces        []string `yaml:"namespaces"`
+	ExcludeNamespaces []string `yaml:"excludeNamespaces"`
+	NamespaceSelector string   `yaml:"namespaceSelector"`
 
-func arrayIntersection(sliceSmall, sliceBig []interface{}) bool {
-	if len(sliceSmall) > len(sliceBig) {
-		return false
-	}
-	for _, vSmall := range sliceSmall {
-		if !contains(sliceBig, vSmall) {
-			return false
-		}
-	}
-	return true
-}
+	Unreferenced        bool       `yaml:"unreferenced"`
+	Ownership           string     `yaml:"ownership"`
+	OwnerKind           string     `yaml:"ownerKind"`
+	Retain              *Retention `yaml:"retain"`
+	HonorTTLAnnotations bool       `yaml:"honorTTLAnnotations"`
 
-func contains(sliceBig []interface{}, vSmall interface{}) bool {
-	for _, vBig := range sliceBig {
-		if typeof(vBig) != typeof(vSmall) {
-			return false
-		}
-		if typeof(vBig) == "map[string]interface {}" {
-			return mapIntersection(vSmall.(map[string]interface{}), vBig.(map[string]interface{}))
-		}
-		if typeof(vBig) == "[]interface {}" {
-			return arrayIntersection(vSmall.([]interface{}), vBig.([]interface{}))
-		}
-		if vSmall != vBig {
-			return false
-		}
-	}
-	return true
-}
+	// DeletePolicy overrides the --cascade and --grace-period of delete
+	// and clean for the objects of the resource identifier
+	DeletePolicy *DeletePolicy `yaml:"deletePolicy"`
 
-func typeof(v interface{}) string {
-	return fmt.Sprintf("%T", v)
+	Criteria `yaml:",inline"`
+
+	// Source is the file, or the cleanup policy, that the resource
+	// identifier was loaded from
+	Source string `yaml:"-"`
+
+	program       cel.Program
+	clusterScoped bool
+	mapper        meta.RESTMapper
 }
 
 func GetDynamicClient(kubeconfig string) dynamic.Interface {
@@ -97,20 +75,14 @@ func GetDynamicClient(kubeconfig string) dynamic.Interface {
 	return client
 }
 
-func ReadFile(file string) []byte {
-	filename, _ := filepath.Abs(file)
-	config, err := ioutil.ReadFile(filename)
+func GetDiscoveryClient(kubeconfig string) discovery.DiscoveryInterface {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
 	if err != nil {
 		panic(err)
 	}
-	return config
-}
-
-func unstructuredArrayInclude(array []unstructured.Unstructured, element unstructured.Unstructured) bool {
-	for _, e := range array {
-		if equality.Semantic.DeepEqual(e.Object, element.Object) {
-			return true
-		}
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		panic(err)
 	}
-	return false
+	return client
 }
@@ -0,0 +1,548 @@
+package kln
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
+)
+
+// StdinFile is the path that reads the configuration from stdin
+const StdinFile = "-"
+
+// Config is the content of the resource identifier files, merged
+type Config struct {
+	Items  []ResourceIdentifier `yaml:"items"`
+	Safety *SafetyPolicy        `yaml:"safety"`
+
+	file    string
+	safety  itemSource
+	sources []itemSource
+}
+
+// itemSource is where a resource identifier, or the safety policy, was
+// defined, so that problems with it can be reported at the right place
+type itemSource struct {
+	file string
+	path string
+	node *yaml.Node
+}
+
+// ValidationError is a problem in the resource identifier file. Line and
+// Column point at the offending yaml node and Path is the path to it, for
+// example items[0].minAge.
+type ValidationError struct {
+	File    string
+	Line    int
+	Column  int
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	position := e.File
+	if e.Line != 0 {
+		position = fmt.Sprintf("%s:%d:%d", e.File, e.Line, e.Column)
+	}
+	if e.Path == "" {
+		return fmt.Sprintf("%s: %s", position, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", position, e.Path, e.Message)
+}
+
+// ValidationErrors are all the problems found in a resource identifier file,
+// one per line
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LoadConfig reads, merges and validates the resource identifier files.
+// Paths can be files, directories, whose yaml and json files are read, or
+// "-" for stdin. Every file can be a stream of yaml documents and can refer
+// to environment variables. See ParseConfig for the validation that it does.
+func LoadConfig(paths ...string) (*Config, error) {
+	files, err := configFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{file: strings.Join(paths, ",")}
+	var errs ValidationErrors
+	for _, file := range files {
+		var data []byte
+		name := file
+		if file == StdinFile {
+			name = "<stdin>"
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, config.parse(name, data)...)
+	}
+	if len(errs) == 0 {
+		errs = config.validate()
+	}
+	if len(errs) != 0 {
+		return nil, errs
+	}
+	return config, nil
+}
+
+// configFiles lists the files to read for the paths. Directories are not
+// read recursively.
+func configFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		if path == StdinFile {
+			if containsString(files, StdinFile) {
+				return nil, errors.New("stdin can only be read once")
+			}
+			files = append(files, path)
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(path, entry.Name()))
+					found = true
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s has no yaml or json files", path)
+		}
+	}
+	return files, nil
+}
+
+// ParseConfig decodes a resource identifier file strictly: unknown fields,
+// values of the wrong type, negative ages, resource identifiers without a gvr
+// or without any criteria, invalid expressions and unset environment
+// variables are all reported, with the position of each one of them in the
+// file. The expressions of the resource identifiers are compiled.
+func ParseConfig(file string, data []byte) (*Config, error) {
+	config := &Config{file: file}
+	errs := config.parse(file, data)
+	if len(errs) == 0 {
+		errs = config.validate()
+	}
+	if len(errs) != 0 {
+		return nil, errs
+	}
+	return config, nil
+}
+
+// parse adds every yaml document of the file to the configuration, once the
+// environment variables in its values are expanded
+func (c *Config) parse(file string, data []byte) ValidationErrors {
+	var errs ValidationErrors
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			return errs
+		}
+		if err != nil {
+			return append(errs, ValidationError{File: file, Message: strings.TrimPrefix(err.Error(), "yaml: ")})
+		}
+		if len(document.Content) == 0 {
+			continue
+		}
+		if envErrs := expandEnv(file, &document); len(envErrs) != 0 {
+			errs = append(errs, envErrs...)
+			continue
+		}
+		errs = append(errs, c.parseDocument(file, document.Content[0])...)
+	}
+}
+
+func (c *Config) parseDocument(file string, root *yaml.Node) ValidationErrors {
+	checker := schemaChecker{file: file}
+	checker.check(root, reflect.TypeOf(Config{}), "")
+	if len(checker.errs) != 0 {
+		return checker.errs
+	}
+	var document Config
+	if err := root.Decode(&document); err != nil {
+		return ValidationErrors{{File: file, Message: err.Error()}}
+	}
+	if document.Safety != nil {
+		source := itemSource{file: file, path: "safety", node: mappingValue(root, "safety")}
+		if c.Safety != nil {
+			return ValidationErrors{source.error("", fmt.Sprintf("the safety policy is already set in %s", c.safety.file))}
+		}
+		c.Safety, c.safety = document.Safety, source
+	}
+	items := mappingValue(root, "items")
+	for i, ri := range document.Items {
+		ri.Source = file
+		c.Items = append(c.Items, ri)
+		c.sources = append(c.sources, itemSource{file: file, path: fmt.Sprintf("items[%d]", i), node: items.Content[i]})
+	}
+	return nil
+}
+
+func (c *Config) validate() ValidationErrors {
+	var errs ValidationErrors
+	if len(c.Items) == 0 {
+		errs = append(errs, ValidationError{File: c.file, Message: "no resource identifiers"})
+	}
+	for i := range c.Items {
+		errs = append(errs, c.validateItem(i)...)
+	}
+	if c.Safety != nil {
+		if err := c.Safety.Validate(); err != nil {
+			errs = append(errs, c.safety.error("", err.Error()))
+		}
+	}
+	return errs
+}
+
+// validateItem validates the i-th resource identifier and compiles its
+// expression
+func (c *Config) validateItem(i int) ValidationErrors {
+	ri := &c.Items[i]
+	if field, err := ri.validateTarget(); err != nil {
+		return ValidationErrors{c.itemError(i, field, err.Error())}
+	}
+	if ri.isEmpty() {
+		return ValidationErrors{c.itemError(i, "", fmt.Sprintf("selects every object of %s, set at least one criterion", ri.target()))}
+	}
+	var errs ValidationErrors
+	if err := ri.validate(); err != nil {
+		errs = append(errs, c.itemError(i, "", err.Error()))
+	}
+	if ri.Expression != "" {
+		program, err := compileExpression(ri.Expression)
+		if err != nil {
+			errs = append(errs, c.itemError(i, "expression", err.Error()))
+		}
+		ri.program = program
+	}
+	return errs
+}
+
+// Resolve looks the resource of every resource identifier up in the
+// discovery information of the cluster. Resource identifiers that are given
+// by kind get the gvr of the kind, at the preferred version of the group
+// unless an apiVersion is given, wildcards are expanded to one resource
+// identifier per resource and all of them learn whether their resource is
+// namespaced or cluster scoped, and how to map the kinds of owners to their
+// resources.
+func (c *Config) Resolve(client discovery.DiscoveryInterface) error {
+	groups, err := restmapper.GetAPIGroupResources(client)
+	if err != nil {
+		return err
+	}
+	mapper := restmapper.NewDiscoveryRESTMapper(groups)
+	served := map[schema.GroupVersion][]metav1.APIResource{}
+	for _, group := range groups {
+		for version, resources := range group.VersionedResources {
+			served[schema.GroupVersion{Group: group.Group.Name, Version: version}] = resources
+		}
+	}
+
+	errs := c.expandWildcards(groups)
+	for i := range c.Items {
+		ri := &c.Items[i]
+		ri.mapper = mapper
+		if ri.Kind != "" {
+			groupKind, versions := ri.groupKind()
+			mapping, err := mapper.RESTMapping(groupKind, versions...)
+			if meta.IsNoMatchError(err) {
+				message := fmt.Sprintf("the cluster does not serve %s%s", ri.target(), kindHint(groupKind, ri.APIVersion != "", groups))
+				errs = append(errs, c.itemError(i, "kind", message))
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			ri.GVR = mapping.Resource
+			ri.clusterScoped = mapping.Scope.Name() == meta.RESTScopeNameRoot
+		} else {
+			resources, ok := served[ri.GVR.GroupVersion()]
+			if !ok {
+				errs = append(errs, c.itemError(i, "gvr", fmt.Sprintf("the cluster does not serve %s", ri.GVR.GroupVersion())))
+				continue
+			}
+			resource, hint := findResource(ri.GVR.Resource, resources)
+			if resource == nil {
+				message := fmt.Sprintf("the cluster does not serve %s in %s%s", ri.GVR.Resource, ri.GVR.GroupVersion(), hint)
+				errs = append(errs, c.itemError(i, "gvr", message))
+				continue
+			}
+			ri.clusterScoped = !resource.Namespaced
+		}
+		if ri.clusterScoped && len(ri.Namespaces) != 0 {
+			errs = append(errs, c.itemError(i, "", fmt.Sprintf("%s is cluster scoped and cannot be selected by namespace", formatGVR(ri.GVR))))
+			continue
+		}
+		if err := ri.validate(); err != nil {
+			errs = append(errs, c.itemError(i, "", err.Error()))
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// findResource returns the served resource with the name. When there is none,
+// it suggests the one that was probably meant instead. Kinds and singular
+// names are a common mistake, so they are looked up too.
+func findResource(name string, served []metav1.APIResource) (*metav1.APIResource, string) {
+	var names []string
+	for i, resource := range served {
+		if strings.Contains(resource.Name, "/") {
+			continue
+		}
+		if resource.Name == name {
+			return &served[i], ""
+		}
+		if strings.EqualFold(resource.Kind, name) || strings.EqualFold(resource.SingularName, name) {
+			return nil, fmt.Sprintf(", did you mean %q?", resource.Name)
+		}
+		names = append(names, resource.Name)
+	}
+	return nil, suggest(name, names)
+}
+
+// kindHint suggests what was probably meant by a kind that the cluster does
+// not serve: the group that serves the kind, or a kind of the group with a
+// similar name
+func kindHint(groupKind schema.GroupKind, byAPIVersion bool, groups []*restmapper.APIGroupResources) string {
+	var kinds []string
+	for _, group := range groups {
+		for _, resource := range group.VersionedResources[group.Group.PreferredVersion.Version] {
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+			if group.Group.Name != groupKind.Group {
+				if resource.Kind == groupKind.Kind && byAPIVersion {
+					return fmt.Sprintf(", did you mean apiVersion %q?", group.Group.PreferredVersion.GroupVersion)
+				}
+				if resource.Kind == groupKind.Kind {
+					return fmt.Sprintf(", did you mean group %q?", group.Group.Name)
+				}
+				continue
+			}
+			if strings.EqualFold(resource.Name, groupKind.Kind) || strings.EqualFold(resource.SingularName, groupKind.Kind) {
+				return fmt.Sprintf(", did you mean %q?", resource.Kind)
+			}
+			kinds = append(kinds, resource.Kind)
+		}
+	}
+	return suggest(groupKind.Kind, kinds)
+}
+
+// SafetyPolicy is the default safety policy with the settings of the
+// configuration applied on top of it
+func (c *Config) SafetyPolicy() SafetyPolicy {
+	policy := Safety
+	if c.Safety != nil {
+		if c.Safety.ProtectedNamespaces != nil {
+			policy.ProtectedNamespaces = c.Safety.ProtectedNamespaces
+		}
+		policy.Deny = c.Safety.Deny
+	}
+	return policy
+}
+
+// itemError reports a problem with the field of the i-th resource identifier,
+// or with the whole resource identifier when the field is empty
+func (c *Config) itemError(i int, field, message string) ValidationError {
+	if i >= len(c.sources) {
+		return ValidationError{File: c.file, Path: fmt.Sprintf("items[%d]", i), Message: message}
+	}
+	return c.sources[i].error(field, message)
+}
+
+func (s itemSource) error(field, message string) ValidationError {
+	node, path := s.node, s.path
+	if field != "" {
+		path = joinPath(path, field)
+		if value := mappingValue(node, field); value != nil {
+			node = value
+		}
+	}
+	err := ValidationError{File: s.file, Path: path, Message: message}
+	if node != nil {
+		err.Line, err.Column = node.Line, node.Column
+	}
+	return err
+}
+
+// validateTarget checks that the resource identifier says which resource it
+// selects, either with a gvr, with a kind or with a wildcard. It returns the
+// field that is wrong along with the error.
+func (ri ResourceIdentifier) validateTarget() (string, error) {
+	hasGVR := ri.GVR != (schema.GroupVersionResource{})
+	if ri.isWildcard() {
+		return ri.validateWildcard(hasGVR)
+	}
+	switch {
+	case ri.Kind == "" && (ri.APIVersion != "" || ri.Group != ""):
+		return "", errors.New("kind is required with apiVersion and group")
+	case ri.Kind == "" && !hasGVR:
+		return "", errors.New("either gvr or kind is required")
+	case ri.Kind != "" && hasGVR:
+		return "gvr", errors.New("gvr cannot be set together with kind")
+	case ri.APIVersion != "" && ri.Group != "":
+		return "group", errors.New("group cannot be set together with apiVersion")
+	case hasGVR && (ri.GVR.Version == "" || ri.GVR.Resource == ""):
+		return "gvr", errors.New("version and resource are required")
+	}
+	if ri.APIVersion != "" {
+		if _, err := schema.ParseGroupVersion(ri.APIVersion); err != nil {
+			return "apiVersion", err
+		}
+	}
+	return "", nil
+}
+
+// groupKind returns the kind of the resource identifier and the version
+// that it asks for, if any
+func (ri ResourceIdentifier) groupKind() (schema.GroupKind, []string) {
+	if ri.APIVersion == "" {
+		return schema.GroupKind{Group: ri.Group, Kind: ri.Kind}, nil
+	}
+	gv, _ := schema.ParseGroupVersion(ri.APIVersion)
+	return gv.WithKind(ri.Kind).GroupKind(), []string{gv.Version}
+}
+
+// target describes the resource that the resource identifier selects
+func (ri ResourceIdentifier) target() string {
+	switch {
+	case ri.AllNamespacedResources:
+		return "every namespaced resource"
+	case ri.Resource != "" && ri.APIVersion != "":
+		return "every resource in " + ri.APIVersion
+	case ri.Resource != "":
+		return "every resource in " + ri.Group
+	case ri.Kind == "":
+		return formatGVR(ri.GVR)
+	case ri.APIVersion != "":
+		return ri.Kind + " in " + ri.APIVersion
+	case ri.Group != "":
+		return ri.Kind + " in " + ri.Group
+	}
+	return ri.Kind
+}
+
+// isEmpty tells whether the resource identifier has nothing but a gvr, in
+// which case it would select every object of the resource
+func (ri ResourceIdentifier) isEmpty() bool {
+	return ri.Criteria.isEmpty() &&
+		ri.Expression == "" &&
+		ri.LabelSelector == "" &&
+		ri.FieldSelector == "" &&
+		len(ri.Namespaces) == 0 &&
+		len(ri.ExcludeNamespaces) == 0 &&
+		ri.NamespaceSelector == "" &&
+		!ri.Unreferenced &&
+		(ri.Ownership == "" || ri.Ownership == OwnershipAny) &&
+		ri.OwnerKind == "" &&
+		ri.Retain == nil &&
+		!ri.HonorTTLAnnotations
+}
+
+// mappingValue returns the value of the key in a yaml mapping, or nil when
+// the node is not a mapping or does not have the key
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// suggest returns a "did you mean" hint with the candidate that is closest
+// to the given name, if any of them is close enough
+func suggest(name string, candidates []string) string {
+	sort.Strings(candidates)
+	best, bestDistance := "", 3
+	for _, candidate := range candidates {
+		if strings.EqualFold(candidate, name) {
+			return fmt.Sprintf(", did you mean %q?", candidate)
+		}
+		if distance := editDistance(strings.ToLower(name), strings.ToLower(candidate)); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+// editDistance is the number of single character insertions, deletions,
+// substitutions and transpositions that turn a into b
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, minInt(d[i][j-1]+1, d[i-1][j-1]+cost))
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,461 @@
+package kln
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	discoveryfake "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	parseTests := []struct {
+		name   string
+		config string
+		errs   []string
+	}{
+		{
+			name: "happy - valid file",
+			config: `
+items:
+  - name: Jobs
+    gvr: {group: batch, version: v1, resource: jobs}
+    minAge: 3d
+    status:
+      succeeded: 1
+    expression: has(object.status.completionTime)
+safety:
+  deny:
+    - kind: Secret
+`,
+		},
+		{
+			name: "happy - anchors and merge keys",
+			config: `
+items:
+  - &jobs
+    gvr: {group: batch, version: v1, resource: jobs}
+    minAge: 1d
+    expression: object.status.succeeded > 0
+  - <<: *jobs
+    name: Jobs
+    minAge: 3d
+`,
+		},
+		{
+			name: "happy - environment variables in comments are left alone",
+			config: `
+# set ${KLN_MIN_AGE} to keep jobs longer
+items:
+  - name: Jobs
+    gvr: {group: batch, version: v1, resource: jobs}
+    minAge: ${KLN_MIN_AGE:-3d}
+    expression: object.status.succeeded > 0
+`,
+		},
+		{
+			name: "sad - unknown fields are reported with their position and a suggestion",
+			config: `
+items:
+  - name: Jobs
+    gvr: {group: batch, version: v1, resource: jobs}
+    minage: 3d
+    stauts:
+      succeeded: 1
+    anyOf:
+      - metdata: {}
+`,
+			errs: []string{
+				`test.yaml:5:5: items[0].minage: unknown field "minage", did you mean "minAge"?`,
+				`test.yaml:6:5: items[0].stauts: unknown field "stauts", did you mean "status"?`,
+				`test.yaml:9:9: items[0].anyOf[0].metdata: unknown field "metdata", did you mean "metadata"?`,
+			},
+		},
+		{
+			name: "sad - values of the wrong type",
+			config: `
+items:
+  - name: Jobs
+    gvr: batch/v1/jobs
+    minAge: soon
+    unreferenced: maybe
+    namespaces: default
+    retain: {keep: many}
+`,
+			errs: []string{
+				`test.yaml:4:10: items[0].gvr: expected a mapping but got "batch/v1/jobs"`,
+				`test.yaml:5:13: items[0].minAge: invalid age "soon"`,
+				`test.yaml:6:19: items[0].unreferenced: expected a boolean but got "maybe"`,
+				`test.yaml:7:17: items[0].namespaces: expected a list but got "default"`,
+				`test.yaml:8:20: items[0].retain.keep: expected an integer but got "many"`,
+			},
+		},
+		{
+			name: "sad - negative ages",
+			config: `
+items:
+  - gvr: {group: batch, version: v1, resource: jobs}
+    maxAge: -2h
+    not:
+      minAge: -1
+`,
+			errs: []string{
+				`test.yaml:4:13: items[0].maxAge: age cannot be negative`,
+				`test.yaml:6:15: items[0].not.minAge: age cannot be negative`,
+			},
+		},
+		{
+			name: "sad - missing gvr, empty resource identifiers and bad expressions",
+			config: `
+items:
+  - name: no gvr
+    minAge: 1
+  - name: everything
+    gvr: {version: v1, resource: pods}
+  - name: bad expression
+    gvr: {version: v1, resource: pods}
+    expression: object.metadata.name
+  - name: bad ownership
+    gvr: {version: v1, resource: pods}
+    ownership: adopted
+safety:
+  protectedNamespaces: ["kube-["]
+`,
+			errs: []string{
+				`test.yaml:3:5: items[0]: either gvr or kind is required`,
+				`test.yaml:5:5: items[1]: selects every object of pods.v1, set at least one criterion`,
+				`test.yaml:9:17: items[2].expression: expression must evaluate to a bool`,
+				`test.yaml:10:5: items[3]: `,
+				`test.yaml:14:3: safety: invalid protected namespace pattern "kube-["`,
+			},
+		},
+		{
+			name: "sad - delete policies",
+			config: `
+items:
+  - gvr: {group: tekton.dev, version: v1, resource: pipelineruns}
+    minAge: 1
+    deletePolicy: {cascade: cascading}
+  - gvr: {group: tekton.dev, version: v1, resource: pipelineruns}
+    minAge: 1
+    deletePolicy: {gracePeriodSeconds: -5}
+`,
+			errs: []string{
+				`test.yaml:3:5: items[0]: deletePolicy.cascade must be one of background|foreground|orphan but got "cascading"`,
+				`test.yaml:6:5: items[1]: deletePolicy.gracePeriodSeconds cannot be negative`,
+			},
+		},
+		{
+			name: "sad - gvr or kind",
+			config: `
+items:
+  - gvr: {group: batch, version: v1, resource: jobs}
+    kind: Job
+    minAge: 1
+  - apiVersion: batch/v1
+    minAge: 1
+  - apiVersion: batch/v1
+    group: batch
+    kind: Job
+    minAge: 1
+  - apiVersion: batch/v1/jobs
+    kind: Job
+    minAge: 1
+  - gvr: {group: batch, resource: jobs}
+    minAge: 1
+  - kind: Job
+    group: batch
+`,
+			errs: []string{
+				`test.yaml:3:10: items[0].gvr: gvr cannot be set together with kind`,
+				`test.yaml:6:5: items[1]: kind is required with apiVersion and group`,
+				`test.yaml:9:12: items[2].group: group cannot be set together with apiVersion`,
+				`test.yaml:12:17: items[3].apiVersion: unexpected GroupVersion string: batch/v1/jobs`,
+				`test.yaml:15:10: items[4].gvr: version and resource are required`,
+				`test.yaml:17:5: items[5]: selects every object of Job in batch, set at least one criterion`,
+			},
+		},
+		{
+			name:   "sad - no resource identifiers",
+			config: "items: []\n",
+			errs:   []string{`test.yaml: no resource identifiers`},
+		},
+		{
+			name:   "sad - empty file",
+			config: "",
+			errs:   []string{`test.yaml: no resource identifiers`},
+		},
+		{
+			name: "happy - multiple documents",
+			config: `
+safety:
+  deny: [{kind: Secret}]
+---
+---
+items:
+  - gvr: {group: batch, version: v1, resource: jobs}
+    minAge: 3d
+    expression: object.status.succeeded > 0
+`,
+		},
+		{
+			name: "sad - the safety policy is set once",
+			config: `
+items:
+  - gvr: {group: batch, version: v1, resource: jobs}
+    minAge: 1
+safety:
+  deny: [{kind: Secret}]
+---
+safety:
+  protectedNamespaces: [prod]
+`,
+			errs: []string{`test.yaml:9:3: safety: the safety policy is already set in test.yaml`},
+		},
+		{
+			name:   "sad - invalid yaml",
+			config: "items:\n  - gvr: {\n",
+			errs:   []string{`test.yaml: line 2: `},
+		},
+	}
+
+	for _, tc := range parseTests {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := ParseConfig("test.yaml", []byte(tc.config))
+			if len(tc.errs) == 0 {
+				if err != nil {
+					t.Fatalf("expected no error but got\n%s", err)
+				}
+				ri := config.Items[len(config.Items)-1]
+				if ri.GVR.Resource != "jobs" || ri.MinAge != 72 || ri.program == nil {
+					t.Errorf("unexpected config %+v", config.Items)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error but did not get any")
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tc.errs) {
+				t.Fatalf("expected %d errors but got\n%s", len(tc.errs), err)
+			}
+			for i, want := range tc.errs {
+				if !strings.HasPrefix(lines[i], want) {
+					t.Errorf("got %q, want %q", lines[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("team-a/jobs.yaml", "items:\n  - name: a-jobs\n    gvr: {group: batch, version: v1, resource: jobs}\n    minAge: ${A_MIN_AGE:-1d}\n")
+	write("team-a/pods.json", `{"items": [{"name": "a-pods", "gvr": {"version": "v1", "resource": "pods"}, "minAge": 1}]}`)
+	write("team-a/README.md", "not a resource identifier file")
+	write("team-b/jobs.yml", "items:\n  - name: b-jobs\n    gvr: {group: batch, version: v1, resource: jobs}\n    minage: 1\n")
+	shared := write("shared.yaml", "items:\n  - name: shared\n    gvr: {version: v1, resource: configmaps}\n    unreferenced: true\n")
+	write("empty/README.md", "")
+
+	t.Run("happy - files and directories are merged and remember their source", func(t *testing.T) {
+		t.Setenv("A_MIN_AGE", "3d")
+		config, err := LoadConfig(filepath.Join(dir, "team-a"), shared)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, ri := range config.Items {
+			got = append(got, ri.Name+"@"+strings.TrimPrefix(ri.Source, dir+"/"))
+		}
+		if strings.Join(got, " ") != "a-jobs@team-a/jobs.yaml a-pods@team-a/pods.json shared@shared.yaml" {
+			t.Errorf("unexpected resource identifiers %v", got)
+		}
+		if config.Items[0].MinAge != 72 {
+			t.Errorf("expected A_MIN_AGE to be expanded but got %v", config.Items[0].MinAge)
+		}
+	})
+
+	t.Run("sad - errors point at the file they are in", func(t *testing.T) {
+		_, err := LoadConfig(filepath.Join(dir, "team-a"), filepath.Join(dir, "team-b"))
+		want := filepath.Join(dir, "team-b/jobs.yml") + `:4:5: items[0].minage: unknown field "minage", did you mean "minAge"?`
+		if err == nil || err.Error() != want {
+			t.Errorf("got %v, want %s", err, want)
+		}
+	})
+
+	t.Run("sad - missing files and directories without yaml", func(t *testing.T) {
+		for _, path := range []string{filepath.Join(dir, "missing.yaml"), filepath.Join(dir, "empty")} {
+			if _, err := LoadConfig(path); err == nil {
+				t.Errorf("expected error for %s but did not get any", path)
+			}
+		}
+		if _, err := LoadConfig(StdinFile, StdinFile); err == nil {
+			t.Error("expected error for reading stdin twice but did not get any")
+		}
+	})
+}
+
+var fakeDiscovery = &discoveryfake.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+	{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "pods", SingularName: "pod", Kind: "Pod", Namespaced: true},
+			{Name: "namespaces", SingularName: "namespace", Kind: "Namespace"},
+		},
+	},
+	{
+		GroupVersion: "batch/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "jobs", SingularName: "job", Kind: "Job", Namespaced: true},
+			{Name: "jobs/status", Kind: "Job", Namespaced: true},
+			{Name: "cronjobs", SingularName: "cronjob", Kind: "CronJob", Namespaced: true},
+		},
+	},
+	{
+		GroupVersion: "batch/v1beta1",
+		APIResources: []metav1.APIResource{
+			{Name: "cronjobs", SingularName: "cronjob", Kind: "CronJob", Namespaced: true},
+		},
+	},
+}}}
+
+func TestResolve(t *testing.T) {
+	t.Run("happy - gvrs and kinds are resolved along with their scope", func(t *testing.T) {
+		config, err := ParseConfig("test.yaml", []byte(`
+items:
+  - gvr: {group: batch, version: v1, resource: jobs}
+    minAge: 1
+  - apiVersion: batch/v1
+    kind: Job
+    minAge: 1
+  - group: batch
+    kind: CronJob
+    minAge: 1
+  - apiVersion: batch/v1beta1
+    kind: CronJob
+    minAge: 1
+  - kind: Namespace
+    labelSelector: preview=true
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := config.Resolve(fakeDiscovery); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, ri := range config.Items {
+			got = append(got, fmt.Sprintf("%s:%t", formatGVR(ri.GVR), ri.clusterScoped))
+		}
+		want := "jobs.v1.batch:false jobs.v1.batch:false cronjobs.v1.batch:false cronjobs.v1beta1.batch:false namespaces.v1:true"
+		if strings.Join(got, " ") != want {
+			t.Errorf("got %v, want %s", got, want)
+		}
+		if namespaces := config.Items[4].listNamespaces(); len(namespaces) != 1 || namespaces[0] != "" {
+			t.Errorf("expected cluster scoped resources to be listed cluster wide but got %v", namespaces)
+		}
+	})
+
+	t.Run("sad - unknown resources and kinds come with suggestions", func(t *testing.T) {
+		config, err := ParseConfig("test.yaml", []byte(`
+items:
+  - gvr: {group: batch, version: v1, resource: Job}
+    minAge: 1
+  - gvr: {group: batch, version: v1, resource: cronjob}
+    minAge: 1
+  - gvr: {group: batch, version: v1, resource: crnojobs}
+    minAge: 1
+  - gvr: {group: batch, version: v1, resource: widgets}
+    minAge: 1
+  - gvr: {group: tekton.dev, version: v1beta1, resource: pipelineruns}
+    minAge: 1
+  - kind: Job
+    minAge: 1
+  - apiVersion: v1
+    kind: Job
+    minAge: 1
+  - apiVersion: batch/v1
+    kind: jobs
+    minAge: 1
+  - group: batch
+    kind: CronJobb
+    minAge: 1
+  - kind: Namespace
+    namespaces: [preview]
+  - kind: Pod
+    unreferenced: true
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			`test.yaml:3:10: items[0].gvr: the cluster does not serve Job in batch/v1, did you mean "jobs"?`,
+			`test.yaml:5:10: items[1].gvr: the cluster does not serve cronjob in batch/v1, did you mean "cronjobs"?`,
+			`test.yaml:7:10: items[2].gvr: the cluster does not serve crnojobs in batch/v1, did you mean "cronjobs"?`,
+			`test.yaml:9:10: items[3].gvr: the cluster does not serve widgets in batch/v1`,
+			`test.yaml:11:10: items[4].gvr: the cluster does not serve tekton.dev/v1beta1`,
+			`test.yaml:13:11: items[5].kind: the cluster does not serve Job, did you mean group "batch"?`,
+			`test.yaml:16:11: items[6].kind: the cluster does not serve Job in v1, did you mean apiVersion "batch/v1"?`,
+			`test.yaml:19:11: items[7].kind: the cluster does not serve jobs in batch/v1, did you mean "Job"?`,
+			`test.yaml:22:11: items[8].kind: the cluster does not serve CronJobb in batch, did you mean "CronJob"?`,
+			`test.yaml:24:5: items[9]: namespaces.v1 is cluster scoped and cannot be selected by namespace`,
+			`test.yaml:26:5: items[10]: unreferenced is only supported for`,
+		}
+		err = config.Resolve(fakeDiscovery)
+		if err == nil {
+			t.Fatal("expected error but did not get any")
+		}
+		lines := strings.Split(err.Error(), "\n")
+		if len(lines) != len(want) {
+			t.Fatalf("expected %d errors but got\n%s", len(want), err)
+		}
+		for i := range want {
+			if !strings.HasPrefix(lines[i], want[i]) {
+				t.Errorf("got %q, want %q", lines[i], want[i])
+			}
+		}
+	})
+
+	t.Run("sad - unresolved kinds cannot be listed", func(t *testing.T) {
+		if _, err := ListResources(nil, ResourceIdentifier{Kind: "Job", Criteria: Criteria{MinAge: 1}}); err == nil {
+			t.Error("expected error but did not get any")
+		}
+	})
+}
+
+func TestConfigSafetyPolicy(t *testing.T) {
+	config, err := ParseConfig("test.yaml", []byte(`
+items:
+  - gvr: {version: v1, resource: pods}
+    minAge: 1
+safety:
+  deny:
+    - name: keep-*
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := config.SafetyPolicy()
+	if strings.Join(policy.ProtectedNamespaces, ",") != strings.Join(SystemNamespaces, ",") || len(policy.Deny) != 1 {
+		t.Errorf("expected the system namespaces to stay protected and one deny rule but got %+v", policy)
+	}
+
+	config.Safety.ProtectedNamespaces = []string{}
+	if policy := config.SafetyPolicy(); len(policy.ProtectedNamespaces) != 0 {
+		t.Errorf("expected no protected namespaces but got %v", policy.ProtectedNamespaces)
+	}
+}
@@ -0,0 +1,123 @@
+package kln
+
+import (
+	"io"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxSchemaDepth is how deep nested criteria (anyOf, allOf and not) are
+// described in the CRD schema. CRD schemas cannot be recursive, so anything
+// deeper is accepted as is and validated by kln when the policy is loaded.
+const maxSchemaDepth = 3
+
+var ageType = reflect.TypeOf(Age(0))
+
+// CustomResourceDefinitions returns the CleanupPolicy and the
+// ClusterCleanupPolicy CRDs. Their schema is generated from
+// ResourceIdentifier, so it always matches what kln accepts.
+func CustomResourceDefinitions() []map[string]interface{} {
+	return []map[string]interface{}{
+		customResourceDefinition(CleanupPolicyKind, "Namespaced", []string{"cp"}),
+		customResourceDefinition(ClusterCleanupPolicyKind, "Cluster", []string{"ccp"}),
+	}
+}
+
+// WriteCustomResourceDefinitions writes the CRDs as a multi document yaml
+// that can be applied with kubectl
+func WriteCustomResourceDefinitions(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	for _, crd := range CustomResourceDefinitions() {
+		if err := encoder.Encode(crd); err != nil {
+			return err
+		}
+	}
+	return encoder.Close()
+}
+
+func customResourceDefinition(kind, scope string, shortNames []string) map[string]interface{} {
+	singular := strings.ToLower(kind)
+	plural := singular[:len(singular)-1] + "ies"
+	return map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": plural + "." + PolicyGroup},
+		"spec": map[string]interface{}{
+			"group": PolicyGroup,
+			"scope": scope,
+			"names": map[string]interface{}{
+				"kind":       kind,
+				"listKind":   kind + "List",
+				"plural":     plural,
+				"singular":   singular,
+				"shortNames": shortNames,
+				"categories": []string{"kln"},
+			},
+			"versions": []interface{}{
+				map[string]interface{}{
+					"name":    PolicyVersion,
+					"served":  true,
+					"storage": true,
+					"schema": map[string]interface{}{
+						"openAPIV3Schema": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"apiVersion": map[string]interface{}{"type": "string"},
+								"kind":       map[string]interface{}{"type": "string"},
+								"metadata":   map[string]interface{}{"type": "object"},
+								"spec":       openAPISchema(reflect.TypeOf(ResourceIdentifier{}), map[reflect.Type]int{}),
+							},
+							"required": []string{"spec"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// openAPISchema describes the go type the same way yamlFields and the yaml
+// decoder see it. Free form criteria preserve unknown fields and ages, which
+// can be numbers or strings, are left to kln to validate.
+func openAPISchema(t reflect.Type, depth map[reflect.Type]int) map[string]interface{} {
+	preserve := map[string]interface{}{"x-kubernetes-preserve-unknown-fields": true}
+	if t == ageType {
+		preserve["description"] = `a number of hours or a duration such as "90m" or "3d"`
+		return preserve
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return openAPISchema(t.Elem(), depth)
+	case reflect.Interface:
+		return preserve
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": openAPISchema(t.Elem(), depth)}
+	case reflect.Map:
+		preserve["type"] = "object"
+		return preserve
+	}
+
+	if depth[t] >= maxSchemaDepth {
+		preserve["type"] = "object"
+		return preserve
+	}
+	depth[t]++
+	defer func() { depth[t]-- }()
+	properties := map[string]interface{}{}
+	for name, field := range yamlFields(t) {
+		properties[name] = openAPISchema(field, depth)
+	}
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
@@ -0,0 +1,77 @@
+package kln
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestCustomResourceDefinitions(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCustomResourceDefinitions(&buf); err != nil {
+		t.Fatal(err)
+	}
+	decoder := yaml.NewDecoder(&buf)
+	var crds []map[string]interface{}
+	for {
+		var crd map[string]interface{}
+		if decoder.Decode(&crd) != nil {
+			break
+		}
+		crds = append(crds, crd)
+	}
+	if len(crds) != 2 {
+		t.Fatalf("expected 2 CRDs but got %d", len(crds))
+	}
+
+	lookup := func(object interface{}, path ...string) interface{} {
+		for _, key := range path {
+			object = object.(map[string]interface{})[key]
+		}
+		return object
+	}
+	names := []string{}
+	for _, crd := range crds {
+		names = append(names, lookup(crd, "metadata", "name").(string)+"="+lookup(crd, "spec", "scope").(string))
+	}
+	if strings.Join(names, " ") != "cleanuppolicies.kln.com=Namespaced clustercleanuppolicies.kln.com=Cluster" {
+		t.Errorf("unexpected CRDs %v", names)
+	}
+
+	spec := lookup(crds[0]["spec"].(map[string]interface{})["versions"].([]interface{})[0], "schema", "openAPIV3Schema", "properties", "spec", "properties")
+	schemaTests := []struct {
+		name string
+		path []string
+		want string
+	}{
+		{name: "happy - untagged fields are lower cased", path: []string{"gvr", "properties", "resource", "type"}, want: "string"},
+		{name: "happy - inlined criteria", path: []string{"ageField", "type"}, want: "string"},
+		{name: "happy - lists", path: []string{"namespaces", "items", "type"}, want: "string"},
+		{name: "happy - ages are numbers or strings", path: []string{"minAge", "x-kubernetes-preserve-unknown-fields"}, want: "true"},
+		{name: "happy - free form criteria", path: []string{"status", "x-kubernetes-preserve-unknown-fields"}, want: "true"},
+		{name: "happy - nested criteria", path: []string{"not", "properties", "anyOf", "items", "properties", "minAge", "x-kubernetes-preserve-unknown-fields"}, want: "true"},
+		{name: "happy - recursion stops", path: []string{"not", "properties", "not", "properties", "not", "properties", "not", "x-kubernetes-preserve-unknown-fields"}, want: "true"},
+		{name: "happy - unexported fields are left out", path: []string{"program"}, want: "<nil>"},
+	}
+	for _, tc := range schemaTests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got interface{} = spec
+			for _, key := range tc.path {
+				got = got.(map[string]interface{})[key]
+			}
+			if s := strings.TrimSpace(strings.ReplaceAll(yamlString(got), "\n", "")); s != tc.want {
+				t.Errorf("got %s, want %s", s, tc.want)
+			}
+		})
+	}
+}
+
+func yamlString(value interface{}) string {
+	if value == nil {
+		return "<nil>"
+	}
+	out, _ := yaml.Marshal(value)
+	return string(out)
+}
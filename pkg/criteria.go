@@ -0,0 +1,227 @@
+package kln
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Criteria are the conditions that an object has to meet in order to be
+// selected. All the criteria that are set have to hold. AnyOf, AllOf and Not
+// group further criteria and can be nested arbitrarily. MinAge and MaxAge are
+// measured from the timestamp at AgeField, which defaults to the
+// creationTimestamp. Fields holds criteria keyed by top-level field name,
+// such as data, type or rules; Metadata, Spec and Status are shorthands for
+// the fields of the same name.
+type Criteria struct {
+	MinAge   Age                    `yaml:"minAge"`
+	MaxAge   Age                    `yaml:"maxAge"`
+	AgeField string                 `yaml:"ageField"`
+	Metadata map[string]interface{} `yaml:"metadata"`
+	Spec     map[string]interface{} `yaml:"spec"`
+	Status   map[string]interface{} `yaml:"status"`
+	Fields   map[string]interface{} `yaml:"fields"`
+	AnyOf    []Criteria             `yaml:"anyOf"`
+	AllOf    []Criteria             `yaml:"allOf"`
+	Not      *Criteria              `yaml:"not"`
+}
+
+func (c Criteria) validate() error {
+	if c.MinAge < 0 {
+		return errors.New("minAge cannot be negative")
+	}
+	if c.MaxAge < 0 {
+		return errors.New("maxAge cannot be negative")
+	}
+	if c.MaxAge != 0 && c.MinAge >= c.MaxAge {
+		return errors.New("minAge must be less than maxAge")
+	}
+	if c.AgeField != "" {
+		if _, err := parseFieldPath(c.AgeField); err != nil {
+			return err
+		}
+	}
+	for _, group := range [][]Criteria{c.AnyOf, c.AllOf} {
+		for _, criteria := range group {
+			if err := criteria.validate(); err != nil {
+				return err
+			}
+		}
+	}
+	if c.Not != nil {
+		return c.Not.validate()
+	}
+	return nil
+}
+
+func (c Criteria) isEmpty() bool {
+	return c.MinAge == 0 && c.MaxAge == 0 &&
+		len(c.Metadata) == 0 && len(c.Spec) == 0 && len(c.Status) == 0 && len(c.Fields) == 0 &&
+		len(c.AnyOf) == 0 && len(c.AllOf) == 0 && c.Not == nil
+}
+
+func (c Criteria) shorthands() map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{"metadata": c.Metadata, "spec": c.Spec, "status": c.Status}
+}
+
+// matchFields matches the field criteria against the object. Fields that
+// are missing from the object do not match, unless the criterion allows it,
+// for example with $exists: false.
+func (c Criteria) matchFields(item unstructured.Unstructured) (bool, error) {
+	if len(c.Fields) != 0 {
+		match, err := matches(c.Fields, item.Object)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+	for field, filter := range c.shorthands() {
+		if len(filter) == 0 {
+			continue
+		}
+		match, err := matches(filter, item.Object[field])
+		if err != nil || !match {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// match evaluates the criteria tree against the object. An object whose age
+// cannot be measured at the top level or under not cannot be evaluated and
+// is skipped, while an anyOf or allOf branch that cannot be measured just
+// does not match.
+func (c Criteria) match(item unstructured.Unstructured, now time.Time) (bool, error) {
+	if c.MinAge != 0 || c.MaxAge != 0 {
+		age, err := ageOf(item, c.AgeField, now)
+		if err != nil {
+			return false, err
+		}
+		if c.MinAge != 0 && age <= c.MinAge.Duration() {
+			return false, nil
+		}
+		if c.MaxAge != 0 && age >= c.MaxAge.Duration() {
+			return false, nil
+		}
+	}
+
+	match, err := c.matchFields(item)
+	if err != nil || !match {
+		return false, err
+	}
+
+	for _, criteria := range c.AllOf {
+		match, err := criteria.matchBranch(item, now)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+
+	if len(c.AnyOf) != 0 {
+		match = false
+		for _, criteria := range c.AnyOf {
+			match, err = criteria.matchBranch(item, now)
+			if err != nil {
+				return false, err
+			}
+			if match {
+				break
+			}
+		}
+		if !match {
+			return false, nil
+		}
+	}
+
+	if c.Not != nil {
+		// an object that cannot be measured must not be selected by
+		// negating the branch, so it is skipped
+		match, err := c.Not.match(item, now)
+		if err != nil || match {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchBranch evaluates a branch of anyOf or allOf, which does not match
+// when the object cannot be evaluated against it
+func (c Criteria) matchBranch(item unstructured.Unstructured, now time.Time) (bool, error) {
+	match, err := c.match(item, now)
+	var skip skipError
+	if errors.As(err, &skip) {
+		return false, nil
+	}
+	return match, err
+}
+
+func filterByCriteria(responseFromServer []unstructured.Unstructured, criteria Criteria) ([]unstructured.Unstructured, error) {
+	var responseList []unstructured.Unstructured
+
+	now := time.Now()
+	for _, item := range responseFromServer {
+		match, err := criteria.match(item, now)
+		var skip skipError
+		if errors.As(err, &skip) {
+			WarningLog.Printf("skipping %s/%s: %s", item.GetNamespace(), item.GetName(), skip)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			responseList = append(responseList, item)
+		}
+	}
+	return responseList, nil
+}
+
+// summary lists the criteria. Since all of them have to hold for an object
+// to be selected, these are the criteria that matched.
+func (c Criteria) summary() []string {
+	var summary []string
+	if c.MinAge != 0 {
+		summary = append(summary, fmt.Sprintf("minAge=%vh", c.MinAge))
+	}
+	if c.MaxAge != 0 {
+		summary = append(summary, fmt.Sprintf("maxAge=%vh", c.MaxAge))
+	}
+	if c.AgeField != "" && (c.MinAge != 0 || c.MaxAge != 0) {
+		summary = append(summary, "ageField="+c.AgeField)
+	}
+	for _, field := range []string{"metadata", "spec", "status"} {
+		var keys []string
+		for k := range c.shorthands()[field] {
+			keys = append(keys, field+"."+k)
+		}
+		sort.Strings(keys)
+		summary = append(summary, keys...)
+	}
+	var fields []string
+	for field := range c.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	summary = append(summary, fields...)
+	if len(c.AllOf) != 0 {
+		summary = append(summary, "allOf("+groupSummary(c.AllOf, ";")+")")
+	}
+	if len(c.AnyOf) != 0 {
+		summary = append(summary, "anyOf("+groupSummary(c.AnyOf, "|")+")")
+	}
+	if c.Not != nil {
+		summary = append(summary, "not("+strings.Join(c.Not.summary(), ",")+")")
+	}
+	return summary
+}
+
+func groupSummary(group []Criteria, separator string) string {
+	var parts []string
+	for _, criteria := range group {
+		parts = append(parts, strings.Join(criteria.summary(), ","))
+	}
+	return strings.Join(parts, separator)
+}
@@ -0,0 +1,265 @@
+package kln
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCriteriaMatch(t *testing.T) {
+	var items []unstructured.Unstructured
+	for _, object := range []string{completedJob, failedJob, invalidPipelineRun, succeededPipelineRun} {
+		var item unstructured.Unstructured
+		if err := item.UnmarshalJSON([]byte(object)); err != nil {
+			t.Fatal(err)
+		}
+		item.SetCreationTimestamp(r1.DeepCopy().GetCreationTimestamp())
+		items = append(items, item)
+	}
+
+	criteriaTests := []struct {
+		name      string
+		criteria  string
+		want      []string
+		wantError bool
+	}{
+		{
+			name: "happy - anyOf selects Failed or Complete",
+			criteria: `
+anyOf:
+  - status: {conditions: [{type: Failed}]}
+  - status: {conditions: [{type: Complete}]}`,
+			want: []string{"backup", "migrate"},
+		},
+		{
+			name: "happy - anyOf is ANDed with the other criteria",
+			criteria: `
+metadata: {labels: {app: backup}}
+anyOf:
+  - status: {conditions: [{type: Failed}]}
+  - status: {conditions: [{type: Complete}]}`,
+			want: []string{"backup"},
+		},
+		{
+			name: "happy - allOf requires every group",
+			criteria: `
+allOf:
+  - metadata: {namespace: ci}
+  - status: {conditions: [{status: "False"}]}`,
+			want: []string{"build-x7k2p"},
+		},
+		{
+			name: "happy - not excludes labelled objects",
+			criteria: `
+metadata: {namespace: ci}
+not:
+  metadata: {labels: {tekton.dev/pipeline: build}}`,
+			want: nil,
+		},
+		{
+			name: "happy - nested groups",
+			criteria: `
+anyOf:
+  - allOf:
+      - metadata: {namespace: ops}
+      - not:
+          status: {conditions: [{type: Complete}]}
+  - not:
+      anyOf:
+        - metadata: {namespace: ops}
+        - status: {conditions: [{reason: Succeeded}]}`,
+			want: []string{"migrate", "build-x7k2p"},
+		},
+		{
+			name: "happy - minAge inside a group",
+			criteria: `
+anyOf:
+  - minAge: 1
+  - metadata: {namespace: ci}`,
+			want: []string{"build-x7k2p", "build-a9f3z"},
+		},
+		{
+			name: "happy - a branch whose age cannot be measured does not match",
+			criteria: `
+anyOf:
+  - {ageField: status.completionTime, minAge: 72}
+  - status: {conditions: [{type: Failed}]}`,
+			want: []string{"migrate"},
+		},
+		{
+			name:     "happy - objects whose age cannot be measured at the top level are skipped",
+			criteria: `{ageField: status.completionTime, minAge: 72, not: {metadata: {namespace: ci}}}`,
+			want:     nil,
+		},
+		{
+			name:     "happy - empty groups are ignored",
+			criteria: `{anyOf: [], allOf: []}`,
+			want:     []string{"backup", "migrate", "build-x7k2p", "build-a9f3z"},
+		},
+		{
+			name: "sad - operator errors inside groups are returned",
+			criteria: `
+not:
+  metadata: {name: {$like: foo}}`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range criteriaTests {
+		t.Run(tc.name, func(t *testing.T) {
+			var criteria Criteria
+			if err := yaml.Unmarshal([]byte(tc.criteria), &criteria); err != nil {
+				t.Fatal(err)
+			}
+			got, err := filterByCriteria(items, criteria)
+			if tc.wantError != (err != nil) {
+				t.Errorf("got error %v, want error %v", err, tc.wantError)
+			}
+			var names []string
+			for _, item := range got {
+				names = append(names, item.GetName())
+			}
+			if strings.Join(names, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("got %v, want %v", names, tc.want)
+			}
+		})
+	}
+}
+
+func TestCriteriaNotSkipsUnmeasurableAges(t *testing.T) {
+	var job unstructured.Unstructured
+	if err := job.UnmarshalJSON([]byte(`{
+		"apiVersion": "batch/v1",
+		"kind": "Job",
+		"metadata": {"name": "running", "namespace": "ops"},
+		"status": {"active": 1, "startTime": "2022-10-01T09:00:00Z"}
+	}`)); err != nil {
+		t.Fatal(err)
+	}
+	job.SetCreationTimestamp(r3.DeepCopy().GetCreationTimestamp())
+
+	for _, criteria := range []string{
+		`{ageField: status.completionTime, minAge: 1h}`,
+		`{not: {ageField: status.completionTime, minAge: 1h}}`,
+		`{not: {not: {ageField: status.completionTime, minAge: 1h}}}`,
+	} {
+		t.Run(criteria, func(t *testing.T) {
+			var c Criteria
+			if err := yaml.Unmarshal([]byte(criteria), &c); err != nil {
+				t.Fatal(err)
+			}
+			got, err := filterByCriteria([]unstructured.Unstructured{job}, c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 0 {
+				t.Errorf("want the running Job to be skipped but it was selected")
+			}
+		})
+	}
+}
+
+func TestCriteriaValidate(t *testing.T) {
+	criteria := Criteria{AnyOf: []Criteria{{Not: &Criteria{MinAge: -1}}}}
+	if err := criteria.validate(); err == nil {
+		t.Error("expected a nested negative minAge to be rejected")
+	}
+}
+
+func TestResourceIdentifierInlineCriteria(t *testing.T) {
+	var ri ResourceIdentifier
+	err := yaml.Unmarshal([]byte(`
+name: Jobs
+gvr: {group: batch, version: v1, resource: jobs}
+minAge: 72
+status: {conditions: [{type: Complete}]}
+not:
+  metadata: {labels: {keep: "true"}}`), &ri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ri.MinAge != 72 || ri.Status == nil || ri.Not == nil || ri.Not.Metadata == nil {
+		t.Errorf("criteria were not decoded inline: %+v", ri)
+	}
+}
+
+func TestCriteriaFields(t *testing.T) {
+	var items []unstructured.Unstructured
+	for _, object := range []string{
+		`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "settings"}, "data": {"mode": "preview"}}`,
+		`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "empty"}}`,
+		`{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "token"}, "type": "kubernetes.io/service-account-token"}`,
+		`{"apiVersion": "v1", "kind": "Event", "metadata": {"name": "backup.1"}, "reason": "BackOff", "involvedObject": {"kind": "Pod", "name": "backup"}}`,
+		`{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "ClusterRole", "metadata": {"name": "wide-open"}, "rules": [{"apiGroups": ["*"], "resources": ["*"], "verbs": ["*"]}]}`,
+	} {
+		var item unstructured.Unstructured
+		if err := item.UnmarshalJSON([]byte(object)); err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+	}
+
+	fieldsTests := []struct {
+		name     string
+		criteria string
+		want     []string
+	}{
+		{
+			name:     "happy - ConfigMap data",
+			criteria: `fields: {data: {mode: preview}}`,
+			want:     []string{"settings"},
+		},
+		{
+			name:     "happy - missing data",
+			criteria: `fields: {kind: ConfigMap, data: {$exists: false}}`,
+			want:     []string{"empty"},
+		},
+		{
+			name:     "happy - Secret type",
+			criteria: `fields: {type: {$regex: "service-account-token$"}}`,
+			want:     []string{"token"},
+		},
+		{
+			name:     "happy - Event reason and involvedObject",
+			criteria: `fields: {reason: BackOff, involvedObject: {kind: Pod}}`,
+			want:     []string{"backup.1"},
+		},
+		{
+			name:     "happy - RBAC rules",
+			criteria: `fields: {rules: [{verbs: ["*"]}]}`,
+			want:     []string{"wide-open"},
+		},
+		{
+			name:     "happy - objects without status or spec do not match",
+			criteria: `{status: {phase: Failed}, spec: {suspend: true}}`,
+			want:     nil,
+		},
+		{
+			name:     "happy - fields are combined with the shorthands",
+			criteria: `{fields: {kind: ConfigMap}, metadata: {name: {$ne: settings}}}`,
+			want:     []string{"empty"},
+		},
+	}
+
+	for _, tc := range fieldsTests {
+		t.Run(tc.name, func(t *testing.T) {
+			var criteria Criteria
+			if err := yaml.Unmarshal([]byte(tc.criteria), &criteria); err != nil {
+				t.Fatal(err)
+			}
+			got, err := filterByCriteria(items, criteria)
+			if err != nil {
+				t.Error(err)
+			}
+			var names []string
+			for _, item := range got {
+				names = append(names, item.GetName())
+			}
+			if strings.Join(names, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("got %v, want %v", names, tc.want)
+			}
+		})
+	}
+}
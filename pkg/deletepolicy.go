@@ -0,0 +1,76 @@
+package kln
+
+import (
+	"errors"
+	"fmt"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	CascadeBackground = "background"
+	CascadeForeground = "foreground"
+	CascadeOrphan     = "orphan"
+)
+
+// DeletePolicy is how objects are deleted. Cascade is the propagation policy
+// of the deletion, the same as kubectl's --cascade: in the background the
+// object is deleted right away and its dependents after it, in the
+// foreground the object is kept until its dependents are gone, and orphan
+// leaves the dependents behind. GracePeriodSeconds overrides the grace
+// period of the object, nil keeps its default.
+type DeletePolicy struct {
+	Cascade            string `yaml:"cascade"`
+	GracePeriodSeconds *int64 `yaml:"gracePeriodSeconds"`
+}
+
+func ValidateCascade(cascade string) error {
+	switch cascade {
+	case "", CascadeBackground, CascadeForeground, CascadeOrphan:
+		return nil
+	}
+	return fmt.Errorf("cascade must be one of %s|%s|%s but got %q", CascadeBackground, CascadeForeground, CascadeOrphan, cascade)
+}
+
+func (p DeletePolicy) validate() error {
+	if err := ValidateCascade(p.Cascade); err != nil {
+		return fmt.Errorf("deletePolicy.%w", err)
+	}
+	if p.GracePeriodSeconds != nil && *p.GracePeriodSeconds < 0 {
+		return errors.New("deletePolicy.gracePeriodSeconds cannot be negative")
+	}
+	return nil
+}
+
+// Override returns the policy with the fields that are set in override
+// replacing its own, so that a resource identifier can override the
+// policy of the command line
+func (p DeletePolicy) Override(override *DeletePolicy) DeletePolicy {
+	if override == nil {
+		return p
+	}
+	if override.Cascade != "" {
+		p.Cascade = override.Cascade
+	}
+	if override.GracePeriodSeconds != nil {
+		p.GracePeriodSeconds = override.GracePeriodSeconds
+	}
+	return p
+}
+
+func (p DeletePolicy) deleteOptions(dryRun DryRun) v1.DeleteOptions {
+	options := v1.DeleteOptions{DryRun: dryRun.options(), GracePeriodSeconds: p.GracePeriodSeconds}
+	var propagation v1.DeletionPropagation
+	switch p.Cascade {
+	case CascadeBackground:
+		propagation = v1.DeletePropagationBackground
+	case CascadeForeground:
+		propagation = v1.DeletePropagationForeground
+	case CascadeOrphan:
+		propagation = v1.DeletePropagationOrphan
+	default:
+		return options
+	}
+	options.PropagationPolicy = &propagation
+	return options
+}
@@ -0,0 +1,48 @@
+package kln
+
+import (
+	"fmt"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DryRun is the dry run mode of flag and delete. In client mode nothing is
+// sent to the server, in server mode the patches and deletions are sent
+// with dryRun=All, so that admission webhooks and RBAC are exercised
+// without any effect.
+type DryRun string
+
+const (
+	DryRunNone   DryRun = "none"
+	DryRunClient DryRun = "client"
+	DryRunServer DryRun = "server"
+)
+
+func ParseDryRun(s string) (DryRun, error) {
+	switch DryRun(s) {
+	case DryRunNone, DryRunClient, DryRunServer:
+		return DryRun(s), nil
+	case "":
+		return DryRunNone, nil
+	}
+	return "", fmt.Errorf("invalid dry run mode %q, must be one of none|client|server", s)
+}
+
+// Change is what was done to an object, or what would have been done to it
+// in a dry run
+type Change struct {
+	Match
+	Action string
+}
+
+func (d DryRun) Enabled() bool {
+	return d == DryRunClient || d == DryRunServer
+}
+
+// options returns the dryRun field of the patch and delete options
+func (d DryRun) options() []string {
+	if d == DryRunServer {
+		return []string{v1.DryRunAll}
+	}
+	return nil
+}
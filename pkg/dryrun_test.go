@@ -0,0 +1,130 @@
+package kln
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// dryRunRecorder records the dryRun options of the patches and deletions,
+// which the fake dynamic client ignores
+type dryRunRecorder struct {
+	dynamic.Interface
+	dryRuns *[]string
+}
+
+type dryRunRecorderResource struct {
+	dynamic.NamespaceableResourceInterface
+	dryRuns *[]string
+}
+
+type dryRunRecorderNamespacedResource struct {
+	dynamic.ResourceInterface
+	dryRuns *[]string
+}
+
+func (r dryRunRecorder) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return dryRunRecorderResource{r.Interface.Resource(gvr), r.dryRuns}
+}
+
+func (r dryRunRecorderResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return dryRunRecorderNamespacedResource{r.NamespaceableResourceInterface.Namespace(namespace), r.dryRuns}
+}
+
+func (r dryRunRecorderNamespacedResource) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, options v1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	*r.dryRuns = append(*r.dryRuns, "patch "+strings.Join(options.DryRun, ","))
+	return &unstructured.Unstructured{}, nil
+}
+
+func (r dryRunRecorderNamespacedResource) Delete(ctx context.Context, name string, options v1.DeleteOptions, subresources ...string) error {
+	*r.dryRuns = append(*r.dryRuns, "delete "+strings.Join(options.DryRun, ","))
+	return nil
+}
+
+func TestDryRun(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(schema.GroupVersionKind{Group: aGVRK.GVR.Group, Version: aGVRK.GVR.Version, Kind: aGVRK.Kind + "List"}, &unstructured.Unstructured{})
+	fake := dynamicfake.NewSimpleDynamicClient(scheme)
+	for _, item := range []*unstructured.Unstructured{r1.DeepCopy(), r2.DeepCopy()} {
+		item.SetLabels(map[string]string{"kln.com/delete": "true"})
+		if _, err := fake.Resource(aGVRK.GVR).Namespace(item.GetNamespace()).Create(context.TODO(), item, v1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ri := ResourceIdentifier{Name: "old-akinds", GVR: aGVRK.GVR, Criteria: Criteria{MinAge: 0.5}}
+
+	t.Run("happy - client dry run only reports", func(t *testing.T) {
+		var dryRuns []string
+		client := dryRunRecorder{fake, &dryRuns}
+		changes, err := FlagForDeletion(client, ri, false, DryRunClient)
+		if err != nil {
+			t.Fatal(err)
+		}
+		deletions, err := DeleteResources(client, ri.GVR, nil, DeletePolicy{}, true, DryRunClient)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dryRuns) != 0 {
+			t.Errorf("expected nothing to be sent but got %v", dryRuns)
+		}
+
+		var buf bytes.Buffer
+		if err := PrintChanges(&buf, append(changes, deletions...), DryRunClient); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		want := []string{
+			"IDENTIFIER NAMESPACE NAME GVR CHANGE",
+			"old-akinds ns name2 akinds.aversion.agroup kln.com/delete: true -> false (client dry run)",
+			"<none> ns name1 akinds.aversion.agroup delete (unverified) (client dry run)",
+		}
+		if len(lines) != 4 {
+			t.Fatalf("expected a header and 3 rows but got\n%s", buf.String())
+		}
+		for i, line := range lines[:3] {
+			if got := strings.Join(strings.Fields(line), " "); got != want[i] {
+				t.Errorf("got %q, want %q", got, want[i])
+			}
+		}
+	})
+
+	t.Run("happy - server dry run sends dryRun=All", func(t *testing.T) {
+		var dryRuns []string
+		client := dryRunRecorder{fake, &dryRuns}
+		if _, err := FlagForDeletion(client, ri, false, DryRunServer); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := DeleteResources(client, ri.GVR, nil, DeletePolicy{}, true, DryRunServer); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(dryRuns, ";"); got != "patch All;delete All;delete All" {
+			t.Errorf("got %s", got)
+		}
+	})
+
+	t.Run("happy - no dry run", func(t *testing.T) {
+		var dryRuns []string
+		client := dryRunRecorder{fake, &dryRuns}
+		if _, err := DeleteResources(client, ri.GVR, nil, DeletePolicy{}, true, DryRunNone); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(dryRuns, ";"); got != "delete ;delete " {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("sad - unknown dry run mode", func(t *testing.T) {
+		if _, err := ParseDryRun("yes"); err == nil {
+			t.Error("expected error but did not get any")
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package kln
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPattern matches ${VAR} and ${VAR:-default}. A reference can be escaped
+// as $${VAR}. Anything else, including $VAR and the $ of match operators
+// such as $ne, is left alone.
+var envPattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces the references to environment variables in the scalars
+// of the yaml tree. Comments are left alone, and since the document is
+// already parsed, a value cannot change its structure or the positions that
+// errors are reported at. Unquoted scalars are typed by their expanded
+// value, so "minAge: ${MIN_AGE}" can still be a number. Variables that are
+// not set and have no default are reported.
+func expandEnv(file string, node *yaml.Node) ValidationErrors {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.MappingNode, yaml.SequenceNode:
+		var errs ValidationErrors
+		for _, child := range node.Content {
+			errs = append(errs, expandEnv(file, child)...)
+		}
+		return errs
+	case yaml.ScalarNode:
+		return expandScalar(file, node)
+	}
+	// aliases point at nodes that are expanded where they are defined
+	return nil
+}
+
+func expandScalar(file string, node *yaml.Node) ValidationErrors {
+	matches := envPattern.FindAllStringSubmatchIndex(node.Value, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	var expanded strings.Builder
+	var errs ValidationErrors
+	last := 0
+	for _, match := range matches {
+		expanded.WriteString(node.Value[last:match[0]])
+		last = match[1]
+		if match[2] < 0 {
+			expanded.WriteString("${")
+			continue
+		}
+		name := node.Value[match[2]:match[3]]
+		if value, ok := os.LookupEnv(name); ok {
+			expanded.WriteString(value)
+		} else if match[4] >= 0 {
+			expanded.WriteString(node.Value[match[6]:match[7]])
+		} else {
+			errs = append(errs, ValidationError{
+				File:    file,
+				Line:    node.Line,
+				Column:  node.Column,
+				Message: fmt.Sprintf("environment variable %s is not set", name),
+			})
+		}
+	}
+	expanded.WriteString(node.Value[last:])
+	node.Value = expanded.String()
+	if node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 && node.Tag == "!!str" {
+		node.Tag = ""
+	}
+	return errs
+}
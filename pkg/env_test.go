@@ -0,0 +1,65 @@
+package kln
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("KLN_NAMESPACE", "preview")
+	t.Setenv("KLN_EMPTY", "")
+	t.Setenv("KLN_SUCCEEDED", "1")
+	t.Setenv("KLN_YAML", "preview\nitems: [{name: injected}]")
+
+	expandTests := []struct {
+		name  string
+		input string
+		want  string
+		err   string
+	}{
+		{name: "happy - variable", input: "namespaces:\n  - ${KLN_NAMESPACE}", want: "namespaces: [preview]"},
+		{name: "happy - default is not used when set", input: "${KLN_NAMESPACE:-default}", want: "preview"},
+		{name: "happy - set to empty", input: "a${KLN_EMPTY:-default}b", want: "ab"},
+		{name: "happy - default", input: "minAge: ${KLN_MIN_AGE:-3d}", want: "minAge: 3d"},
+		{name: "happy - empty default", input: "name: '${KLN_NAME:-}'", want: "name: ''"},
+		{name: "happy - escaped", input: "expression: $${KLN_NAMESPACE}", want: "expression: ${KLN_NAMESPACE}"},
+		{name: "happy - operators and bare variables are left alone", input: "status: {phase: {$ne: $KLN_NAMESPACE}}", want: "status: {phase: {$ne: $KLN_NAMESPACE}}"},
+		{name: "happy - unquoted values are typed after expansion", input: "succeeded: ${KLN_SUCCEEDED}", want: "succeeded: 1"},
+		{name: "happy - quoted values stay strings", input: "succeeded: '${KLN_SUCCEEDED}'", want: "succeeded: '1'"},
+		{name: "happy - comments are left alone", input: "# set ${KLN_NAME} to the release\nname: ${KLN_NAMESPACE}", want: "name: preview"},
+		{name: "happy - values cannot change the structure", input: "name: ${KLN_YAML}", want: "name: \"preview\\nitems: [{name: injected}]\""},
+		{name: "sad - not set", input: "items:\n  - name: ${KLN_NAME}", err: "test.yaml:2:11: environment variable KLN_NAME is not set"},
+		{name: "sad - positions are those of the file", input: "items:\n  - name: ${KLN_YAML}\n    namespaces: '${KLN_NAME}'", err: "test.yaml:3:17: environment variable KLN_NAME is not set"},
+	}
+
+	for _, tc := range expandTests {
+		t.Run(tc.name, func(t *testing.T) {
+			var document yaml.Node
+			if err := yaml.Unmarshal([]byte(tc.input), &document); err != nil {
+				t.Fatal(err)
+			}
+			errs := expandEnv("test.yaml", &document)
+			if tc.err != "" {
+				if errs.Error() != tc.err {
+					t.Errorf("got error %q, want %q", errs, tc.err)
+				}
+				return
+			}
+			if len(errs) != 0 {
+				t.Fatal(errs)
+			}
+			var got, want interface{}
+			if err := document.Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if err := yaml.Unmarshal([]byte(tc.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package kln
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var celEnv *cel.Env
+
+func init() {
+	var err error
+	celEnv, err = cel.NewEnv(
+		cel.Variable("object", cel.DynType),
+		cel.Variable("now", cel.TimestampType),
+		cel.Variable("age", cel.DurationType),
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Compile compiles the CEL expression of the resource identifier so that it
+// is not compiled again for every object. It should be called once after the
+// resource identifier is loaded.
+func (ri *ResourceIdentifier) Compile() error {
+	if ri.Expression == "" {
+		return nil
+	}
+	program, err := compileExpression(ri.Expression)
+	if err != nil {
+		return fmt.Errorf("resource identifier %q: %w", ri.Name, err)
+	}
+	ri.program = program
+	return nil
+}
+
+func compileExpression(expression string) (cel.Program, error) {
+	ast, issues := celEnv.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid expression: %w", issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("expression must evaluate to a bool but evaluates to %s", ast.OutputType())
+	}
+	return celEnv.Program(ast)
+}
+
+// filterByExpression keeps the objects for which the program evaluates to
+// true. Objects on which the evaluation fails, for example because a field is
+// missing, are skipped and reported.
+func filterByExpression(responseFromServer []unstructured.Unstructured, program cel.Program) []unstructured.Unstructured {
+	var responseList []unstructured.Unstructured
+
+	now := time.Now()
+	for _, item := range responseFromServer {
+		out, _, err := program.Eval(map[string]interface{}{
+			"object": item.Object,
+			"now":    now,
+			"age":    now.Sub(item.GetCreationTimestamp().Time),
+		})
+		if err != nil {
+			WarningLog.Printf("skipping %s/%s: %s", item.GetNamespace(), item.GetName(), err)
+			continue
+		}
+		if match, ok := out.Value().(bool); ok && match {
+			responseList = append(responseList, item)
+		}
+	}
+	return responseList
+}
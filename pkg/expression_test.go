@@ -0,0 +1,114 @@
+package kln
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCompile(t *testing.T) {
+	compileTests := []struct {
+		name      string
+		ri        ResourceIdentifier
+		wantError string
+	}{
+		{
+			name: "happy - compiles a condition expression",
+			ri:   ResourceIdentifier{Name: "failed", Expression: "object.status.conditions.exists(c, c.type == 'Succeeded' && c.status == 'False')"},
+		},
+		{
+			name: "happy - compiles an expression on age and now",
+			ri:   ResourceIdentifier{Name: "old", Expression: "age > duration('24h') && now > timestamp('2022-01-01T00:00:00Z')"},
+		},
+		{
+			name: "happy - nothing to compile",
+			ri:   ResourceIdentifier{Name: "empty"},
+		},
+		{
+			name:      "sad - syntax error names the resource identifier",
+			ri:        ResourceIdentifier{Name: "broken", Expression: "object.status.conditions.exists(c, "},
+			wantError: `resource identifier "broken": invalid expression`,
+		},
+		{
+			name:      "sad - expression is not a bool",
+			ri:        ResourceIdentifier{Name: "string", Expression: "'foo'"},
+			wantError: `resource identifier "string": expression must evaluate to a bool`,
+		},
+		{
+			name:      "sad - undeclared variable",
+			ri:        ResourceIdentifier{Name: "undeclared", Expression: "obj.metadata.name == 'foo'"},
+			wantError: `resource identifier "undeclared": invalid expression`,
+		},
+	}
+
+	for _, tc := range compileTests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.ri.Compile()
+			if tc.wantError == "" && err != nil {
+				t.Errorf("got err %s", err)
+			}
+			if tc.wantError != "" && (err == nil || !strings.HasPrefix(err.Error(), tc.wantError)) {
+				t.Errorf("expected error starting with\n%s\nbut got\n%v", tc.wantError, err)
+			}
+			if tc.wantError == "" && tc.ri.Expression != "" && tc.ri.program == nil {
+				t.Error("expected the program to be set")
+			}
+		})
+	}
+}
+
+func TestFilterByExpression(t *testing.T) {
+	var items []unstructured.Unstructured
+	for _, object := range []string{completedJob, failedJob, invalidPipelineRun, succeededPipelineRun} {
+		var item unstructured.Unstructured
+		if err := item.UnmarshalJSON([]byte(object)); err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+	}
+
+	expressionTests := []struct {
+		name       string
+		expression string
+		want       []string
+	}{
+		{
+			name:       "happy - failed PipelineRuns",
+			expression: "has(object.status.conditions) && object.status.conditions.exists(c, c.type == 'Succeeded' && c.status == 'False')",
+			want:       []string{"build-x7k2p"},
+		},
+		{
+			name:       "happy - cross-field expression",
+			expression: "object.kind == 'Job' && object.metadata.namespace == 'ops' && !has(object.metadata.labels)",
+			want:       []string{"migrate"},
+		},
+		{
+			name:       "happy - age of objects without creationTimestamp",
+			expression: "age > duration('1h')",
+			want:       []string{"backup", "migrate", "build-x7k2p", "build-a9f3z"},
+		},
+		{
+			name:       "sad - objects failing the evaluation are skipped",
+			expression: "object.status.failed > 0",
+			want:       []string{"migrate"},
+		},
+	}
+
+	for _, tc := range expressionTests {
+		t.Run(tc.name, func(t *testing.T) {
+			program, err := compileExpression(tc.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := filterByExpression(items, program)
+			var names []string
+			for _, item := range got {
+				names = append(names, item.GetName())
+			}
+			if strings.Join(names, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("got %v, want %v", names, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package kln
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// fieldPath is a parsed path to a field of an object, for example
+// "status.completionTime", "metadata.ownerReferences[0].name",
+// "status.conditions[type=Complete].lastTransitionTime" or
+// "metadata.labels['tekton.dev/pipeline']".
+type fieldPath []pathStep
+
+type pathStep struct {
+	key      string
+	index    int
+	selector []string
+	isIndex  bool
+}
+
+func parseFieldPath(path string) (fieldPath, error) {
+	var steps fieldPath
+	rest := path
+	for rest != "" {
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		if end > 0 {
+			steps = append(steps, pathStep{key: rest[:end]})
+		}
+		rest = rest[end:]
+		if strings.HasPrefix(rest, ".") {
+			rest = rest[1:]
+			if rest == "" || rest[0] == '.' || rest[0] == '[' {
+				return nil, fmt.Errorf("invalid field path %q", path)
+			}
+			continue
+		}
+		if !strings.HasPrefix(rest, "[") {
+			continue
+		}
+		closing := strings.Index(rest, "]")
+		if closing < 0 {
+			return nil, fmt.Errorf("invalid field path %q: missing ]", path)
+		}
+		step, err := parseSelector(rest[1:closing])
+		if err != nil {
+			return nil, fmt.Errorf("invalid field path %q: %w", path, err)
+		}
+		steps = append(steps, step)
+		rest = rest[closing+1:]
+		if rest != "" && rest[0] != '.' && rest[0] != '[' {
+			return nil, fmt.Errorf("invalid field path %q", path)
+		}
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("invalid field path %q", path)
+	}
+	return steps, nil
+}
+
+func parseSelector(selector string) (pathStep, error) {
+	if len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+		return pathStep{key: selector[1 : len(selector)-1]}, nil
+	}
+	if key, value, ok := strings.Cut(selector, "="); ok {
+		return pathStep{selector: []string{key, value}}, nil
+	}
+	index, err := strconv.Atoi(selector)
+	if err != nil || index < 0 {
+		return pathStep{}, fmt.Errorf("invalid selector [%s]", selector)
+	}
+	return pathStep{index: index, isIndex: true}, nil
+}
+
+// lookup returns the value at the path and whether it was found
+func (path fieldPath) lookup(object map[string]interface{}) (interface{}, bool) {
+	var current interface{} = object
+	for _, step := range path {
+		switch {
+		case step.isIndex:
+			array, ok := current.([]interface{})
+			if !ok || step.index >= len(array) {
+				return nil, false
+			}
+			current = array[step.index]
+		case step.selector != nil:
+			array, ok := current.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			current = nil
+			for _, element := range array {
+				if m, ok := element.(map[string]interface{}); ok && fmt.Sprint(m[step.selector[0]]) == step.selector[1] {
+					current = element
+					break
+				}
+			}
+			if current == nil {
+				return nil, false
+			}
+		default:
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if current, ok = m[step.key]; !ok {
+				return nil, false
+			}
+		}
+	}
+	return current, true
+}
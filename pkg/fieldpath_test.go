@@ -0,0 +1,54 @@
+package kln
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestFieldPathLookup(t *testing.T) {
+	var pipelineRun unstructured.Unstructured
+	if err := pipelineRun.UnmarshalJSON([]byte(succeededPipelineRun)); err != nil {
+		t.Fatal(err)
+	}
+
+	lookupTests := []struct {
+		path      string
+		want      interface{}
+		wantFound bool
+		wantError bool
+	}{
+		{path: "metadata.name", want: "build-a9f3z", wantFound: true},
+		{path: "metadata.labels['tekton.dev/pipeline']", want: "build", wantFound: true},
+		{path: `metadata.labels["tekton.dev/pipeline"]`, want: "build", wantFound: true},
+		{path: "status.childReferences[1].pipelineTaskName", want: "test", wantFound: true},
+		{path: "status.childReferences[pipelineTaskName=clone].name", want: "build-a9f3z-clone", wantFound: true},
+		{path: "status.conditions[type=Succeeded].reason", want: "Succeeded", wantFound: true},
+		{path: "status.conditions[type=Failed].reason"},
+		{path: "status.childReferences[2].name"},
+		{path: "metadata.name.first"},
+		{path: "spec.timeout"},
+		{path: "", wantError: true},
+		{path: "metadata..name", wantError: true},
+		{path: "metadata.", wantError: true},
+		{path: "status.conditions[type=Failed", wantError: true},
+		{path: "status.conditions[-1]", wantError: true},
+		{path: "status.conditions[0]reason", wantError: true},
+	}
+
+	for _, tc := range lookupTests {
+		t.Run(tc.path, func(t *testing.T) {
+			path, err := parseFieldPath(tc.path)
+			if tc.wantError != (err != nil) {
+				t.Fatalf("got error %v, want error %v", err, tc.wantError)
+			}
+			if err != nil {
+				return
+			}
+			got, found := path.lookup(pipelineRun.Object)
+			if found != tc.wantFound || got != tc.want {
+				t.Errorf("got %v %v, want %v %v", got, found, tc.want, tc.wantFound)
+			}
+		})
+	}
+}
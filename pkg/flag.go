@@ -2,37 +2,72 @@ package kln
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
 
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 )
 
-func FlagForDeletion(client dynamic.Interface, ri ResourceIdentifier, cleanSwitch bool) error {
+// FlaggedByAnnotation records the name of the resource identifier that
+// flagged an object, so that delete can check that it still selects the
+// object before deleting it
+const FlaggedByAnnotation = "kln.com/flagged-by"
+
+// FlagForDeletion labels the objects that the resource identifier selects
+// with kln.com/delete, true when cleanSwitch is set and false otherwise.
+// Flagged objects are annotated with the name of the resource identifier.
+// It returns the label changes, which are only reported in a client dry run.
+func FlagForDeletion(client dynamic.Interface, ri ResourceIdentifier, cleanSwitch bool, dryRun DryRun) ([]Change, error) {
 	resources, err := ListResources(client, ri)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(resources) == 0 {
 		InfoLog.Printf("did not find any resources that match the following resource identifier\n%v", ri)
-		return nil
+		return nil, nil
 	}
 
+	var changes []Change
 	for _, resource := range resources {
-		var patch []byte
 		ns := resource.GetNamespace()
 		name := resource.GetName()
 
+		var flaggedBy interface{}
 		if cleanSwitch {
-			patch = []byte(`{"metadata":{"labels":{"kln.com/delete":"true"}}}`)
-		} else {
-			patch = []byte(`{"metadata":{"labels":{"kln.com/delete":"false"}}}`)
+			flaggedBy = ri.Name
+		}
+		patch := flagPatch(cleanSwitch, map[string]interface{}{FlaggedByAnnotation: flaggedBy})
+		current, ok := resource.GetLabels()["kln.com/delete"]
+		if !ok {
+			current = "<none>"
+		}
+		changes = append(changes, Change{
+			Match:  Match{RI: ri, Object: resource},
+			Action: fmt.Sprintf("kln.com/delete: %s -> %t", current, cleanSwitch),
+		})
+		if dryRun == DryRunClient {
+			continue
 		}
-		_, err := client.Resource(ri.GVR).Namespace(ns).Patch(context.TODO(), name, types.MergePatchType, patch, v1.PatchOptions{})
+		_, err := client.Resource(ri.GVR).Namespace(ns).Patch(context.TODO(), name, types.MergePatchType, patch, v1.PatchOptions{DryRun: dryRun.options()})
 		if err != nil {
-			return err
+			return changes, err
 		}
 	}
-	return nil
+	return changes, nil
+}
+
+// flagPatch is a merge patch that labels an object kln.com/delete=flag and
+// sets the annotations, removing the ones that are nil
+func flagPatch(flag bool, annotations map[string]interface{}) []byte {
+	patch, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels":      map[string]string{"kln.com/delete": strconv.FormatBool(flag)},
+			"annotations": annotations,
+		},
+	})
+	return patch
 }
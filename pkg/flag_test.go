@@ -29,14 +29,14 @@ func TestFlagForDeletion(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	ri := ResourceIdentifier{GVR: aGVRK.GVR, MinAge: 0.5}
+	ri := ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{MinAge: 0.5}}
 	labelFalse := []byte(`{"metadata":{"labels":{"kln.com/delete":"false"}}}`)
 	labelTrue := []byte(`{"metadata":{"labels":{"kln.com/delete":"true"}}}`)
 	client.Resource(ri.GVR).Namespace("ns").Patch(context.TODO(), "name1", types.MergePatchType, labelTrue, v1.PatchOptions{})
 	client.Resource(ri.GVR).Namespace("ns3").Patch(context.TODO(), "name3", types.MergePatchType, labelFalse, v1.PatchOptions{})
 
 	t.Run("happy - flagging resources", func(t *testing.T) {
-		err := FlagForDeletion(client, ri, true)
+		_, err := FlagForDeletion(client, ri, true, DryRunNone)
 		if err != nil {
 			t.Error(err)
 		}
@@ -47,7 +47,7 @@ func TestFlagForDeletion(t *testing.T) {
 	})
 
 	t.Run("happy - unflag resources", func(t *testing.T) {
-		err := FlagForDeletion(client, ri, false)
+		_, err := FlagForDeletion(client, ri, false, DryRunNone)
 		if err != nil {
 			t.Error(err)
 		}
@@ -2,7 +2,7 @@ package kln
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"time"
 
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -13,59 +13,125 @@ import (
 func ListResources(client dynamic.Interface, ri ResourceIdentifier) ([]unstructured.Unstructured, error) {
 	var responseList []unstructured.Unstructured
 
-	responseFromServer, err := client.Resource(ri.GVR).List(context.TODO(), v1.ListOptions{})
+	if ri.GVR.Resource == "" {
+		return nil, fmt.Errorf("resource identifier %q has not been resolved to a gvr", ri.Name)
+	}
+	if err := ri.validate(); err != nil {
+		return nil, err
+	}
+
+	listOptions, err := ri.listOptions()
 	if err != nil {
 		return nil, err
 	}
 
-	responseList, err = filterByAge(responseFromServer, ri.MinAge)
+	responseFromServer, err := ri.listInScope(client, listOptions)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(responseList) != 0 {
-		responseList = filterByField(responseList, map[string]interface{}{"metadata": ri.Metadata, "spec": ri.Spec, "status": ri.Status})
+	responseFromServer = Safety.filter(responseFromServer)
+
+	var expiredList []unstructured.Unstructured
+	if ri.HonorTTLAnnotations {
+		responseFromServer, expiredList = partitionByTTL(responseFromServer, time.Now())
 	}
-	return responseList, nil
-}
 
-func filterByAge(responseFromServer *unstructured.UnstructuredList, minAge float64) ([]unstructured.Unstructured, error) {
-	var responseList []unstructured.Unstructured
+	responseList, err = filterByCriteria(responseFromServer, ri.Criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	if ri.Expression != "" && len(responseList) != 0 {
+		if ri.program == nil {
+			if err := ri.Compile(); err != nil {
+				return nil, err
+			}
+		}
+		responseList = filterByExpression(responseList, ri.program)
+	}
+
+	responseList, err = ri.filterInUse(client, responseList)
+	if err != nil {
+		return nil, err
+	}
 
-	if minAge == 0 {
-		return responseFromServer.Items, nil
+	if ri.Retain != nil {
+		responseList = filterByRetention(responseList, *ri.Retain)
 	}
 
-	if minAge < 0 {
-		return nil, errors.New("minAge cannot be negative")
+	// an expired ttl takes precedence over the criteria, but not over the
+	// guards that keep objects that are still in use
+	expiredList, err = ri.filterInUse(client, expiredList)
+	if err != nil {
+		return nil, err
+	}
+	return append(responseList, expiredList...), nil
+}
+
+// filterInUse keeps the objects that pass the unreferenced and the
+// ownership guards of the resource identifier
+func (ri ResourceIdentifier) filterInUse(client dynamic.Interface, responseList []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+	var err error
+	if ri.Unreferenced && len(responseList) != 0 {
+		responseList, err = filterByReferences(client, responseList, ri.GVR.Resource)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	for _, item := range responseFromServer.Items {
-		age := time.Since(item.GetCreationTimestamp().Time)
-		if age.Hours() > minAge {
-			responseList = append(responseList, item)
+	if (ri.Ownership != "" && ri.Ownership != OwnershipAny) || ri.OwnerKind != "" {
+		responseList, err = filterByOwnership(client, ri.mapper, responseList, ri.Ownership, ri.OwnerKind)
+		if err != nil {
+			return nil, err
 		}
 	}
 	return responseList, nil
 }
 
-func filterByField(responseFromServer []unstructured.Unstructured, filters map[string]interface{}) []unstructured.Unstructured {
-	var responseList []unstructured.Unstructured
+// listInScope lists the objects of the resource that are in the namespaces
+// that the resource identifier selects
+func (ri ResourceIdentifier) listInScope(client dynamic.Interface, listOptions v1.ListOptions) ([]unstructured.Unstructured, error) {
+	inNamespaceScope, err := ri.namespaceFilter(client)
+	if err != nil {
+		return nil, err
+	}
 
-	for field, v := range filters {
-		filter := v.(map[string]interface{})
-		if filter == nil {
-			continue
+	var responseFromServer []unstructured.Unstructured
+	for _, namespace := range ri.listNamespaces() {
+		response, err := client.Resource(ri.GVR).Namespace(namespace).List(context.TODO(), listOptions)
+		if err != nil {
+			return nil, err
 		}
-
-		for _, item := range responseFromServer {
-			objectField := item.Object[field].(map[string]interface{})
-			if mapIntersection(filter, objectField) && !unstructuredArrayInclude(responseList, item) {
-				responseList = append(responseList, item)
+		for _, item := range response.Items {
+			if inNamespaceScope(item.GetNamespace()) {
+				responseFromServer = append(responseFromServer, item)
 			}
 		}
+	}
+	return responseFromServer, nil
+}
 
-		responseFromServer = responseList
+func (ri ResourceIdentifier) validate() error {
+	if err := ri.Criteria.validate(); err != nil {
+		return err
+	}
+	if err := validateOwnership(ri.Ownership); err != nil {
+		return err
+	}
+	// resource identifiers that are given by kind are checked once resolved
+	if ri.Unreferenced && ri.GVR.Resource != "" {
+		if err := validateUnreferenced(ri.GVR); err != nil {
+			return err
+		}
+	}
+	if ri.Retain != nil {
+		if err := ri.Retain.validate(); err != nil {
+			return err
+		}
+	}
+	if ri.DeletePolicy != nil {
+		return ri.DeletePolicy.validate()
 	}
-	return responseFromServer
+	return nil
 }
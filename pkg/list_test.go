@@ -135,21 +135,43 @@ func TestListResources(t *testing.T) {
 
 		{
 			name: "happy - finds resources given minAge",
-			ri:   ResourceIdentifier{GVR: aGVRK.GVR, MinAge: 0.5},
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{MinAge: 0.5}},
 			want: []map[string]interface{}{response2.Object, response3.Object},
 			skip: false,
 		},
 
 		{
 			name: "sad - finds resources given minAge",
-			ri:   ResourceIdentifier{GVR: aGVRK.GVR, MinAge: 1.5},
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{MinAge: 1.5}},
 			want: []map[string]interface{}{},
 			skip: false,
 		},
 
+		{
+			name: "happy - finds resources given maxAge",
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{MinAge: 0.5, MaxAge: 1}},
+			want: []map[string]interface{}{response2.Object},
+			skip: false,
+		},
+
+		{
+			name: "happy - skips resources without ageField",
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{MinAge: 0.1, AgeField: "status.completionTime"}},
+			want: []map[string]interface{}{},
+			skip: false,
+		},
+
+		{
+			name:      "sad - minAge greater than maxAge",
+			ri:        ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{MinAge: 2, MaxAge: 1}},
+			want:      nil,
+			wantError: errors.New("minAge must be less than maxAge"),
+			skip:      false,
+		},
+
 		{
 			name:      "sad - minAge in negative",
-			ri:        ResourceIdentifier{GVR: aGVRK.GVR, MinAge: -1.5},
+			ri:        ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{MinAge: -1.5}},
 			want:      nil,
 			wantError: errors.New("minAge cannot be negative"),
 			skip:      false,
@@ -157,66 +179,95 @@ func TestListResources(t *testing.T) {
 
 		{
 			name: "happy - finds resources given metadata",
-			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Metadata: map[string]interface{}{"namespace": "ns"}},
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{Metadata: map[string]interface{}{"namespace": "ns"}}},
 			want: []map[string]interface{}{response1.Object, response2.Object},
 			skip: false,
 		},
 
 		{
 			name: "sad - finds resources given metadata",
-			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Metadata: map[string]interface{}{"namespace": "ns", "name": "fake"}},
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{Metadata: map[string]interface{}{"namespace": "ns", "name": "fake"}}},
 			want: []map[string]interface{}{},
 			skip: false,
 		},
 
 		{
 			name: "happy - finds resources given minAge and metadata",
-			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Metadata: map[string]interface{}{"namespace": "ns"}, MinAge: 0.5},
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{Metadata: map[string]interface{}{"namespace": "ns"}, MinAge: 0.5}},
 			want: []map[string]interface{}{response2.Object},
 			skip: false,
 		},
 
 		{
 			name: "sad - finds resources given minAge and metadata",
-			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Metadata: map[string]interface{}{"name": "fake"}, MinAge: 0.5},
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{Metadata: map[string]interface{}{"name": "fake"}, MinAge: 0.5}},
 			want: []map[string]interface{}{},
 			skip: false,
 		},
 
 		{
 			name: "happy - finds resources given status and metadata",
-			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Status: map[string]interface{}{"foo": "bar"}, Metadata: map[string]interface{}{"namespace": "ns"}},
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{Status: map[string]interface{}{"foo": "bar"}, Metadata: map[string]interface{}{"namespace": "ns"}}},
 			want: []map[string]interface{}{response1.Object, response2.Object},
 			skip: false,
 		},
 
 		{
 			name: "happy - finds resources given nested status",
-			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Status: map[string]interface{}{"status": map[string]interface{}{"baz": map[string]interface{}{"deep": "nest"}}}},
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{Status: map[string]interface{}{"status": map[string]interface{}{"baz": map[string]interface{}{"deep": "nest"}}}}},
 			want: []map[string]interface{}{response2.Object},
 			skip: false,
 		},
 
 		{
 			name: "happy - finds correct resources given multiple resources with desired key-value pair",
-			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Status: map[string]interface{}{"tomato": "potato"}},
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{Status: map[string]interface{}{"tomato": "potato"}}},
 			want: []map[string]interface{}{response1.Object},
 			skip: false,
 		},
 
 		{
 			name: "happy - finds correct resources given multiple resources with desired key-value pair - nested",
-			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Status: map[string]interface{}{"status": map[string]interface{}{"tomato": "potato"}}},
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{Status: map[string]interface{}{"status": map[string]interface{}{"tomato": "potato"}}}},
 			want: []map[string]interface{}{response2.Object},
 			skip: false,
 		},
 
 		{
 			name: "sad - finds resources given status",
-			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Status: map[string]interface{}{"status": map[string]interface{}{"baz": "fail"}}},
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{Status: map[string]interface{}{"status": map[string]interface{}{"baz": "fail"}}}},
 			want: []map[string]interface{}{response3.Object},
 			skip: false,
 		},
+
+		{
+			name: "happy - finds resources given labelSelector",
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, LabelSelector: "!app"},
+			want: []map[string]interface{}{response1.Object, response2.Object, response3.Object},
+			skip: false,
+		},
+
+		{
+			name: "sad - finds resources given labelSelector",
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, LabelSelector: "app=ci"},
+			want: []map[string]interface{}{},
+			skip: false,
+		},
+
+		{
+			name: "happy - finds resources given expression",
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Expression: "object.status.foo == 'bar' && age > duration('30m')"},
+			want: []map[string]interface{}{response2.Object},
+			skip: false,
+		},
+
+		{
+			name:      "sad - invalid expression",
+			ri:        ResourceIdentifier{GVR: aGVRK.GVR, Name: "invalid", Expression: "object.status.foo"},
+			want:      nil,
+			wantError: errors.New(`resource identifier "invalid": expression must evaluate to a bool but evaluates to dyn`),
+			skip:      false,
+		},
 	}
 
 	for _, tc := range listTests {
@@ -0,0 +1,217 @@
+package kln
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var regexCache sync.Map
+
+// matches reports whether value is a superset of criterion. Every key of a
+// map criterion has to be present in value and match, every element of an
+// array criterion has to match at least one element of the value array and
+// scalars have to be equal. A null criterion matches a missing or null value.
+// A map whose keys are all operators ($ne, $in, ...) is evaluated as an
+// operator object instead, see matchOperators.
+func matches(criterion, value interface{}) (bool, error) {
+	return matchValue(criterion, value, true)
+}
+
+func matchValue(criterion, value interface{}, present bool) (bool, error) {
+	switch c := criterion.(type) {
+	case map[string]interface{}:
+		isOperator, err := isOperatorObject(c)
+		if err != nil {
+			return false, err
+		}
+		if isOperator {
+			return matchOperators(c, value, present)
+		}
+		v, ok := value.(map[string]interface{})
+		if !ok && present && value != nil {
+			return false, nil
+		}
+		// a missing parent is missing all of its keys, which operators such
+		// as $exists: false still match
+		for key, cv := range c {
+			vv, found := v[key]
+			if cv == nil && vv == nil {
+				continue
+			}
+			match, err := matchValue(cv, vv, found)
+			if err != nil || !match {
+				return false, err
+			}
+		}
+		return true, nil
+	case []interface{}:
+		v, ok := value.([]interface{})
+		if !ok {
+			return false, nil
+		}
+		for _, ce := range c {
+			match, err := anyMatches(ce, v)
+			if err != nil || !match {
+				return false, err
+			}
+		}
+		return true, nil
+	default:
+		return present && scalarEqual(criterion, value), nil
+	}
+}
+
+func anyMatches(criterion interface{}, values []interface{}) (bool, error) {
+	for _, value := range values {
+		match, err := matches(criterion, value)
+		if err != nil {
+			return false, err
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func isOperatorObject(criterion map[string]interface{}) (bool, error) {
+	operators := 0
+	for key := range criterion {
+		if strings.HasPrefix(key, "$") {
+			operators++
+		}
+	}
+	if operators != 0 && operators != len(criterion) {
+		return false, fmt.Errorf("operators cannot be mixed with fields in %v", criterion)
+	}
+	return operators != 0, nil
+}
+
+// matchOperators evaluates an operator object against value. All the
+// operators of the object have to hold. present is false when the key that
+// holds value is missing from the object, in which case only $exists: false,
+// $ne and $notIn can match.
+func matchOperators(operators map[string]interface{}, value interface{}, present bool) (bool, error) {
+	exists := present && value != nil
+	for operator, argument := range operators {
+		var match bool
+		var err error
+		switch operator {
+		case "$exists":
+			want, ok := argument.(bool)
+			if !ok {
+				return false, fmt.Errorf("$exists expects a boolean but got %v", argument)
+			}
+			match = exists == want
+		case "$ne":
+			match, err = matches(argument, value)
+			match = !exists || !match
+		case "$in", "$notIn":
+			arguments, ok := argument.([]interface{})
+			if !ok {
+				return false, fmt.Errorf("%s expects a list but got %v", operator, argument)
+			}
+			match = false
+			if exists {
+				match, err = matchesAny(arguments, value)
+			}
+			if operator == "$notIn" {
+				match = !match
+			}
+		case "$regex":
+			match, err = matchRegex(argument, value)
+		case "$gt", "$lt":
+			match, err = compareNumbers(operator, argument, value)
+		default:
+			return false, fmt.Errorf("unknown operator %s", operator)
+		}
+		if err != nil || !match {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchesAny(arguments []interface{}, value interface{}) (bool, error) {
+	for _, argument := range arguments {
+		match, err := matches(argument, value)
+		if err != nil {
+			return false, err
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func matchRegex(argument, value interface{}) (bool, error) {
+	pattern, ok := argument.(string)
+	if !ok {
+		return false, fmt.Errorf("$regex expects a string but got %v", argument)
+	}
+	re, err := compileRegex(pattern)
+	if err != nil {
+		return false, err
+	}
+	s, ok := value.(string)
+	return ok && re.MatchString(s), nil
+}
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $regex %q: %w", pattern, err)
+	}
+	regexCache.Store(pattern, re)
+	return re, nil
+}
+
+func compareNumbers(operator string, argument, value interface{}) (bool, error) {
+	bound, ok := toFloat(argument)
+	if !ok {
+		return false, fmt.Errorf("%s expects a number but got %v", operator, argument)
+	}
+	n, ok := toFloat(value)
+	if !ok {
+		return false, nil
+	}
+	if operator == "$gt" {
+		return n > bound, nil
+	}
+	return n < bound, nil
+}
+
+// scalarEqual compares two scalars. Numbers are compared by value since
+// yaml decodes them as int while unstructured objects hold int64 or float64.
+func scalarEqual(a, b interface{}) bool {
+	fa, aIsNumber := toFloat(a)
+	fb, bIsNumber := toFloat(b)
+	if aIsNumber || bIsNumber {
+		return aIsNumber && bIsNumber && fa == fb
+	}
+	return a == b
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,490 @@
+package kln
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type matchSpec struct {
+	describe  string
+	it        string
+	object    string
+	criteria  string
+	want      bool
+	wantError bool
+}
+
+const (
+	completedJob = `{
+		"apiVersion": "batch/v1",
+		"kind": "Job",
+		"metadata": {"name": "backup", "namespace": "ops", "labels": {"app": "backup"}},
+		"spec": {"backoffLimit": 6, "completions": 1, "parallelism": 1},
+		"status": {
+			"succeeded": 1,
+			"conditions": [
+				{"type": "Suspended", "status": "False"},
+				{"type": "Complete", "status": "True", "lastTransitionTime": "2022-10-01T10:00:00Z"}
+			]
+		}
+	}`
+
+	failedJob = `{
+		"apiVersion": "batch/v1",
+		"kind": "Job",
+		"metadata": {"name": "migrate", "namespace": "ops"},
+		"status": {
+			"failed": 6,
+			"conditions": [
+				{"type": "Failed", "status": "True", "reason": "BackoffLimitExceeded"}
+			]
+		}
+	}`
+
+	invalidPipelineRun = `{
+		"apiVersion": "tekton.dev/v1beta1",
+		"kind": "PipelineRun",
+		"metadata": {"name": "build-x7k2p", "namespace": "ci", "labels": {"tekton.dev/pipeline": "build"}},
+		"spec": {"pipelineRef": {"name": "build"}, "params": [{"name": "revision", "value": "main"}, {"name": "url", "value": "https://example.com/repo.git"}]},
+		"status": {
+			"conditions": [
+				{"type": "Succeeded", "status": "False", "reason": "PipelineValidationFailed", "message": "pipeline build can't be run"}
+			]
+		}
+	}`
+
+	succeededPipelineRun = `{
+		"apiVersion": "tekton.dev/v1beta1",
+		"kind": "PipelineRun",
+		"metadata": {"name": "build-a9f3z", "namespace": "ci", "labels": {"tekton.dev/pipeline": "build"}},
+		"spec": {"pipelineRef": {"name": "build"}},
+		"status": {
+			"conditions": [
+				{"type": "Succeeded", "status": "True", "reason": "Succeeded"}
+			],
+			"childReferences": [
+				{"kind": "TaskRun", "name": "build-a9f3z-clone", "pipelineTaskName": "clone"},
+				{"kind": "TaskRun", "name": "build-a9f3z-test", "pipelineTaskName": "test"}
+			]
+		}
+	}`
+)
+
+var matchSpecs = []matchSpec{
+	{
+		describe: "map criterion",
+		it:       "matches when every key matches",
+		object:   completedJob,
+		criteria: `metadata: {name: backup, namespace: ops}`,
+		want:     true,
+	},
+	{
+		describe: "map criterion",
+		it:       "does not match when one of the keys does not match",
+		object:   completedJob,
+		criteria: `metadata: {name: backup, namespace: default}`,
+		want:     false,
+	},
+	{
+		describe: "map criterion",
+		it:       "checks sibling keys after a nested map",
+		object:   completedJob,
+		criteria: `{metadata: {labels: {app: backup}}, spec: {backoffLimit: 3}}`,
+		want:     false,
+	},
+	{
+		describe: "map criterion",
+		it:       "does not match when a key is missing",
+		object:   failedJob,
+		criteria: `metadata: {labels: {app: backup}}`,
+		want:     false,
+	},
+	{
+		describe: "map criterion",
+		it:       "matches an empty map against any map",
+		object:   failedJob,
+		criteria: `metadata: {}`,
+		want:     true,
+	},
+	{
+		describe: "map criterion",
+		it:       "matches a null value against a missing key",
+		object:   failedJob,
+		criteria: `metadata: {labels: null}`,
+		want:     true,
+	},
+	{
+		describe: "map criterion",
+		it:       "does not match a null value against a present key",
+		object:   completedJob,
+		criteria: `metadata: {labels: null}`,
+		want:     false,
+	},
+	{
+		describe: "map criterion",
+		it:       "does not match against a scalar",
+		object:   completedJob,
+		criteria: `spec: {backoffLimit: {value: 6}}`,
+		want:     false,
+	},
+	{
+		describe: "scalar criterion",
+		it:       "compares yaml ints with json numbers",
+		object:   completedJob,
+		criteria: `spec: {backoffLimit: 6, completions: 1}`,
+		want:     true,
+	},
+	{
+		describe: "scalar criterion",
+		it:       "compares floats with ints",
+		object:   completedJob,
+		criteria: `status: {succeeded: 1.0}`,
+		want:     true,
+	},
+	{
+		describe: "scalar criterion",
+		it:       "does not match a number against a string",
+		object:   completedJob,
+		criteria: `status: {succeeded: "1"}`,
+		want:     false,
+	},
+	{
+		describe: "array criterion",
+		it:       "matches a Job condition regardless of its position",
+		object:   completedJob,
+		criteria: `status: {conditions: [{type: Complete, status: "True"}]}`,
+		want:     true,
+	},
+	{
+		describe: "array criterion",
+		it:       "does not match a Job condition of another type",
+		object:   failedJob,
+		criteria: `status: {conditions: [{type: Complete, status: "True"}]}`,
+		want:     false,
+	},
+	{
+		describe: "array criterion",
+		it:       "requires all the fields of a condition to be in the same element",
+		object:   completedJob,
+		criteria: `status: {conditions: [{type: Suspended, status: "True"}]}`,
+		want:     false,
+	},
+	{
+		describe: "array criterion",
+		it:       "requires every criterion element to match some element",
+		object:   completedJob,
+		criteria: `status: {conditions: [{type: Complete}, {type: Suspended}]}`,
+		want:     true,
+	},
+	{
+		describe: "array criterion",
+		it:       "does not match when one of the criterion elements has no match",
+		object:   completedJob,
+		criteria: `status: {conditions: [{type: Complete}, {type: Failed}]}`,
+		want:     false,
+	},
+	{
+		describe: "array criterion",
+		it:       "matches a PipelineRun that failed validation",
+		object:   invalidPipelineRun,
+		criteria: `status: {conditions: [{reason: PipelineValidationFailed}]}`,
+		want:     true,
+	},
+	{
+		describe: "array criterion",
+		it:       "does not match a PipelineRun that succeeded",
+		object:   succeededPipelineRun,
+		criteria: `status: {conditions: [{reason: PipelineValidationFailed}]}`,
+		want:     false,
+	},
+	{
+		describe: "array criterion",
+		it:       "matches elements out of order",
+		object:   succeededPipelineRun,
+		criteria: `status: {childReferences: [{pipelineTaskName: test}, {pipelineTaskName: clone}]}`,
+		want:     true,
+	},
+	{
+		describe: "array criterion",
+		it:       "matches nested maps inside elements",
+		object:   invalidPipelineRun,
+		criteria: `{metadata: {labels: {tekton.dev/pipeline: build}}, spec: {pipelineRef: {name: build}, params: [{name: url}]}}`,
+		want:     true,
+	},
+	{
+		describe: "array criterion",
+		it:       "matches scalar elements",
+		object:   `{"apiVersion": "v1", "kind": "Namespace", "spec": {"finalizers": ["kubernetes", "kln.com/protect"]}}`,
+		criteria: `spec: {finalizers: [kln.com/protect]}`,
+		want:     true,
+	},
+	{
+		describe: "array criterion",
+		it:       "matches an empty array against any array",
+		object:   succeededPipelineRun,
+		criteria: `status: {conditions: []}`,
+		want:     true,
+	},
+	{
+		describe: "array criterion",
+		it:       "does not match against a map",
+		object:   succeededPipelineRun,
+		criteria: `spec: {pipelineRef: [{name: build}]}`,
+		want:     false,
+	},
+	{
+		describe: "$ne",
+		it:       "matches a different value",
+		object:   failedJob,
+		criteria: `status: {conditions: [{type: {$ne: Complete}}]}`,
+		want:     true,
+	},
+	{
+		describe: "$ne",
+		it:       "does not match the same value",
+		object:   completedJob,
+		criteria: `metadata: {name: {$ne: backup}}`,
+		want:     false,
+	},
+	{
+		describe: "$ne",
+		it:       "matches a missing key",
+		object:   failedJob,
+		criteria: `metadata: {labels: {$ne: {app: backup}}}`,
+		want:     true,
+	},
+	{
+		describe: "$in",
+		it:       "matches any of the values",
+		object:   invalidPipelineRun,
+		criteria: `status: {conditions: [{reason: {$in: [Failed, PipelineValidationFailed]}}]}`,
+		want:     true,
+	},
+	{
+		describe: "$in",
+		it:       "does not match values outside the list",
+		object:   succeededPipelineRun,
+		criteria: `status: {conditions: [{reason: {$in: [Failed, PipelineValidationFailed]}}]}`,
+		want:     false,
+	},
+	{
+		describe: "$in",
+		it:       "does not match a missing key",
+		object:   failedJob,
+		criteria: `metadata: {labels: {app: {$in: [backup]}}}`,
+		want:     false,
+	},
+	{
+		describe: "$in",
+		it:       "compares numbers by value",
+		object:   failedJob,
+		criteria: `status: {failed: {$in: [3, 6]}}`,
+		want:     true,
+	},
+	{
+		describe:  "$in",
+		it:        "fails when the argument is not a list",
+		object:    failedJob,
+		criteria:  `status: {failed: {$in: 6}}`,
+		wantError: true,
+	},
+	{
+		describe: "$notIn",
+		it:       "matches values outside the list",
+		object:   succeededPipelineRun,
+		criteria: `status: {conditions: [{reason: {$notIn: [Failed, PipelineValidationFailed]}}]}`,
+		want:     true,
+	},
+	{
+		describe: "$notIn",
+		it:       "does not match values in the list",
+		object:   completedJob,
+		criteria: `metadata: {namespace: {$notIn: [ops, kube-system]}}`,
+		want:     false,
+	},
+	{
+		describe: "$notIn",
+		it:       "matches a missing key",
+		object:   failedJob,
+		criteria: `metadata: {labels: {$notIn: [{app: backup}]}}`,
+		want:     true,
+	},
+	{
+		describe: "$exists",
+		it:       "matches a missing label with false",
+		object:   invalidPipelineRun,
+		criteria: `metadata: {labels: {keep: {$exists: false}}}`,
+		want:     true,
+	},
+	{
+		describe: "$exists",
+		it:       "does not match a present label with false",
+		object:   completedJob,
+		criteria: `metadata: {labels: {app: {$exists: false}}}`,
+		want:     false,
+	},
+	{
+		describe: "$exists",
+		it:       "matches a present label with true",
+		object:   completedJob,
+		criteria: `metadata: {labels: {app: {$exists: true}}}`,
+		want:     true,
+	},
+	{
+		describe: "$exists",
+		it:       "matches a label with false when the object has no labels",
+		object:   failedJob,
+		criteria: `metadata: {labels: {keep: {$exists: false}}}`,
+		want:     true,
+	},
+	{
+		describe: "$exists",
+		it:       "does not match a label with true when the object has no labels",
+		object:   failedJob,
+		criteria: `metadata: {labels: {keep: {$exists: true}}}`,
+		want:     false,
+	},
+	{
+		describe: "missing parent",
+		it:       "matches $ne and $notIn under a missing parent",
+		object:   failedJob,
+		criteria: `metadata: {labels: {app: {$ne: backup}, team: {$notIn: [ops]}}}`,
+		want:     true,
+	},
+	{
+		describe: "missing parent",
+		it:       "matches $exists: false under missing nested parents",
+		object:   failedJob,
+		criteria: `{metadata: {annotations: {example.com/owner: {$exists: false}}}, spec: {template: {spec: {nodeName: {$exists: false}}}}}`,
+		want:     true,
+	},
+	{
+		describe: "missing parent",
+		it:       "does not match values or $in under a missing parent",
+		object:   failedJob,
+		criteria: `metadata: {labels: {app: {$in: [backup]}}}`,
+		want:     false,
+	},
+	{
+		describe: "missing parent",
+		it:       "does not match a scalar under a missing parent",
+		object:   failedJob,
+		criteria: `metadata: {labels: {app: backup}}`,
+		want:     false,
+	},
+	{
+		describe:  "$exists",
+		it:        "fails when the argument is not a boolean",
+		object:    completedJob,
+		criteria:  `metadata: {labels: {app: {$exists: "yes"}}}`,
+		wantError: true,
+	},
+	{
+		describe: "$regex",
+		it:       "matches a name",
+		object:   invalidPipelineRun,
+		criteria: `metadata: {name: {$regex: "^build-"}}`,
+		want:     true,
+	},
+	{
+		describe: "$regex",
+		it:       "does not match a different name",
+		object:   completedJob,
+		criteria: `metadata: {name: {$regex: "^preview-"}}`,
+		want:     false,
+	},
+	{
+		describe: "$regex",
+		it:       "does not match a number",
+		object:   completedJob,
+		criteria: `status: {succeeded: {$regex: "1"}}`,
+		want:     false,
+	},
+	{
+		describe:  "$regex",
+		it:        "fails on an invalid pattern",
+		object:    completedJob,
+		criteria:  `metadata: {name: {$regex: "("}}`,
+		wantError: true,
+	},
+	{
+		describe: "$gt and $lt",
+		it:       "compare ints",
+		object:   failedJob,
+		criteria: `status: {failed: {$gt: 5, $lt: 7}}`,
+		want:     true,
+	},
+	{
+		describe: "$gt and $lt",
+		it:       "compare floats",
+		object:   failedJob,
+		criteria: `status: {failed: {$gt: 5.5}}`,
+		want:     true,
+	},
+	{
+		describe: "$gt and $lt",
+		it:       "are exclusive",
+		object:   failedJob,
+		criteria: `status: {failed: {$lt: 6}}`,
+		want:     false,
+	},
+	{
+		describe: "$gt and $lt",
+		it:       "do not match strings",
+		object:   failedJob,
+		criteria: `metadata: {name: {$gt: 1}}`,
+		want:     false,
+	},
+	{
+		describe:  "$gt and $lt",
+		it:        "fail when the argument is not a number",
+		object:    failedJob,
+		criteria:  `status: {failed: {$gt: "5"}}`,
+		wantError: true,
+	},
+	{
+		describe: "operators",
+		it:       "match scalar array elements",
+		object:   `{"apiVersion": "v1", "kind": "Namespace", "spec": {"finalizers": ["kubernetes", "kln.com/protect"]}}`,
+		criteria: `spec: {finalizers: [{$regex: "^kln.com/"}]}`,
+		want:     true,
+	},
+	{
+		describe:  "operators",
+		it:        "cannot be mixed with fields",
+		object:    completedJob,
+		criteria:  `metadata: {name: {$ne: foo, bar: baz}}`,
+		wantError: true,
+	},
+	{
+		describe:  "operators",
+		it:        "fail when unknown",
+		object:    completedJob,
+		criteria:  `metadata: {name: {$like: back}}`,
+		wantError: true,
+	},
+}
+
+func TestMatches(t *testing.T) {
+	for _, spec := range matchSpecs {
+		t.Run(spec.describe+"/"+spec.it, func(t *testing.T) {
+			var object unstructured.Unstructured
+			if err := object.UnmarshalJSON([]byte(spec.object)); err != nil {
+				t.Fatal(err)
+			}
+			var criteria map[string]interface{}
+			if err := yaml.Unmarshal([]byte(spec.criteria), &criteria); err != nil {
+				t.Fatal(err)
+			}
+			got, err := matches(criteria, object.Object)
+			if spec.wantError != (err != nil) {
+				t.Errorf("got error %v, want error %v", err, spec.wantError)
+			}
+			if got != spec.want {
+				t.Errorf("got %v, want %v", got, spec.want)
+			}
+		})
+	}
+}
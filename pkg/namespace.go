@@ -0,0 +1,103 @@
+package kln
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var namespaceGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+// SystemNamespaces are left out unless a resource identifier lists them by
+// name in its namespaces. They are also protected by the default Safety
+// policy, which has to be relaxed as well to act on them.
+var SystemNamespaces = []string{"kube-system", "kube-public", "kube-node-lease"}
+
+// listNamespaces returns the namespaces to issue List calls in. When the
+// resource identifier lists namespaces by name, each one is listed on its
+// own. Otherwise the empty namespace lists across all namespaces.
+func (ri ResourceIdentifier) listNamespaces() []string {
+	if ri.clusterScoped || len(ri.Namespaces) == 0 {
+		return []string{""}
+	}
+	for _, pattern := range ri.Namespaces {
+		if isPattern(pattern) {
+			return []string{""}
+		}
+	}
+	return ri.Namespaces
+}
+
+// namespaceFilter returns a function that reports whether objects in a
+// namespace are in scope of the resource identifier. Cluster scoped objects
+// are always in scope.
+func (ri ResourceIdentifier) namespaceFilter(client dynamic.Interface) (func(string) bool, error) {
+	for _, pattern := range append(append([]string{}, ri.Namespaces...), ri.ExcludeNamespaces...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid namespace pattern %q: %w", pattern, err)
+		}
+	}
+
+	var selected map[string]bool
+	if ri.NamespaceSelector != "" {
+		if _, err := labels.Parse(ri.NamespaceSelector); err != nil {
+			return nil, fmt.Errorf("invalid namespaceSelector: %w", err)
+		}
+		namespaces, err := client.Resource(namespaceGVR).List(context.TODO(), v1.ListOptions{LabelSelector: ri.NamespaceSelector})
+		if err != nil {
+			return nil, err
+		}
+		selected = map[string]bool{}
+		for _, namespace := range namespaces.Items {
+			selected[namespace.GetName()] = true
+		}
+	}
+
+	return func(namespace string) bool {
+		if namespace == "" {
+			return true
+		}
+		if len(ri.Namespaces) != 0 && !matchesAnyPattern(namespace, ri.Namespaces) {
+			return false
+		}
+		if matchesAnyPattern(namespace, ri.ExcludeNamespaces) {
+			return false
+		}
+		if isSystemNamespace(namespace) && !containsString(ri.Namespaces, namespace) {
+			return false
+		}
+		return selected == nil || selected[namespace]
+	}, nil
+}
+
+func isPattern(s string) bool {
+	return strings.ContainsAny(s, `*?[\`)
+}
+
+func matchesAnyPattern(s string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if match, _ := path.Match(pattern, s); match {
+			return true
+		}
+	}
+	return false
+}
+
+func isSystemNamespace(namespace string) bool {
+	return containsString(SystemNamespaces, namespace)
+}
+
+func containsString(slice []string, s string) bool {
+	for _, e := range slice {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package kln
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestListResourcesNamespaces(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		aGVRK.GVR:    aGVRK.Kind + "List",
+		namespaceGVR: "NamespaceList",
+	})
+	namespaceLabels := map[string]map[string]string{
+		"ci":              {"team": "ci"},
+		"preview-1":       {"team": "web", "env": "preview"},
+		"preview-2":       {"team": "api", "env": "preview"},
+		"kube-system":     nil,
+		"kube-node-lease": nil,
+	}
+	for namespace, labels := range namespaceLabels {
+		ns := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Namespace"}}
+		ns.SetName(namespace)
+		ns.SetLabels(labels)
+		if _, err := client.Resource(namespaceGVR).Create(context.TODO(), ns, v1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		item := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": aGVRK.GVR.Group + "/" + aGVRK.GVR.Version, "kind": aGVRK.Kind}}
+		item.SetNamespace(namespace)
+		item.SetName("item")
+		if _, err := client.Resource(aGVRK.GVR).Namespace(namespace).Create(context.TODO(), item, v1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	namespaceTests := []struct {
+		name          string
+		ri            ResourceIdentifier
+		want          []string
+		wantListCalls []string
+		wantError     bool
+		safety        *SafetyPolicy
+	}{
+		{
+			name:          "happy - system namespaces are excluded by default",
+			ri:            ResourceIdentifier{GVR: aGVRK.GVR},
+			want:          []string{"ci", "preview-1", "preview-2"},
+			wantListCalls: []string{""},
+		},
+		{
+			name:          "happy - explicit namespaces are listed one by one",
+			ri:            ResourceIdentifier{GVR: aGVRK.GVR, Namespaces: []string{"ci", "preview-2"}},
+			want:          []string{"ci", "preview-2"},
+			wantListCalls: []string{"ci", "preview-2"},
+		},
+		{
+			name:          "happy - system namespaces can be opted in by name",
+			ri:            ResourceIdentifier{GVR: aGVRK.GVR, Namespaces: []string{"kube-system"}},
+			want:          []string{"kube-system"},
+			wantListCalls: []string{"kube-system"},
+			safety:        &SafetyPolicy{},
+		},
+		{
+			name:          "happy - opted in system namespaces are still protected by the safety policy",
+			ri:            ResourceIdentifier{GVR: aGVRK.GVR, Namespaces: []string{"kube-system"}},
+			want:          nil,
+			wantListCalls: []string{"kube-system"},
+		},
+		{
+			name:          "happy - glob patterns list across namespaces",
+			ri:            ResourceIdentifier{GVR: aGVRK.GVR, Namespaces: []string{"preview-*", "kube-*"}},
+			want:          []string{"preview-1", "preview-2"},
+			wantListCalls: []string{""},
+		},
+		{
+			name:          "happy - excluded namespaces",
+			ri:            ResourceIdentifier{GVR: aGVRK.GVR, ExcludeNamespaces: []string{"preview-?"}},
+			want:          []string{"ci"},
+			wantListCalls: []string{""},
+		},
+		{
+			name:          "happy - exclusion wins over inclusion",
+			ri:            ResourceIdentifier{GVR: aGVRK.GVR, Namespaces: []string{"ci", "preview-1"}, ExcludeNamespaces: []string{"preview-*"}},
+			want:          []string{"ci"},
+			wantListCalls: []string{"ci", "preview-1"},
+		},
+		{
+			name:          "happy - namespaceSelector",
+			ri:            ResourceIdentifier{GVR: aGVRK.GVR, NamespaceSelector: "env=preview,team!=api"},
+			want:          []string{"preview-1"},
+			wantListCalls: []string{"", ""},
+		},
+		{
+			name:      "sad - invalid pattern",
+			ri:        ResourceIdentifier{GVR: aGVRK.GVR, ExcludeNamespaces: []string{"preview-["}},
+			wantError: true,
+		},
+		{
+			name:      "sad - invalid namespaceSelector",
+			ri:        ResourceIdentifier{GVR: aGVRK.GVR, NamespaceSelector: "env in (preview"},
+			wantError: true,
+		},
+	}
+
+	for _, tc := range namespaceTests {
+		t.Run(tc.name, func(t *testing.T) {
+			client.ClearActions()
+			if tc.safety != nil {
+				defer func(safety SafetyPolicy) { Safety = safety }(Safety)
+				Safety = *tc.safety
+			}
+			got, err := ListResources(client, tc.ri)
+			if tc.wantError != (err != nil) {
+				t.Errorf("got error %v, want error %v", err, tc.wantError)
+			}
+			var namespaces []string
+			for _, item := range got {
+				namespaces = append(namespaces, item.GetNamespace())
+			}
+			sort.Strings(namespaces)
+			if strings.Join(namespaces, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("got objects in %v, want %v", namespaces, tc.want)
+			}
+			if tc.wantError {
+				return
+			}
+			var listCalls []string
+			for _, action := range client.Actions() {
+				if list, ok := action.(k8stesting.ListAction); ok {
+					listCalls = append(listCalls, list.GetNamespace())
+				}
+			}
+			if strings.Join(listCalls, ",") != strings.Join(tc.wantListCalls, ",") {
+				t.Errorf("got list calls in %q, want %q", listCalls, tc.wantListCalls)
+			}
+		})
+	}
+}
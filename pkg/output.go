@@ -0,0 +1,280 @@
+package kln
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+const (
+	OutputTable = "table"
+	OutputWide  = "wide"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+	OutputName  = "name"
+)
+
+var OutputFormats = []string{OutputTable, OutputWide, OutputJSON, OutputYAML, OutputName}
+
+// Match is an object that was selected by a resource identifier
+type Match struct {
+	RI     ResourceIdentifier
+	Object unstructured.Unstructured
+}
+
+// expiredByTTL reports whether the object was selected because its ttl or
+// expires annotation expired rather than by the criteria
+func (m Match) expiredByTTL(now time.Time) bool {
+	if !m.RI.HonorTTLAnnotations {
+		return false
+	}
+	isExpired, _, err := expired(m.Object, now)
+	return err == nil && isExpired
+}
+
+func ValidateOutputFormat(format string) error {
+	for _, f := range OutputFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q, must be one of %s", format, strings.Join(OutputFormats, "|"))
+}
+
+func PrintMatches(w io.Writer, format string, matches []Match) error {
+	switch format {
+	case OutputTable:
+		return printTable(w, matches, false)
+	case OutputWide:
+		return printTable(w, matches, true)
+	case OutputJSON:
+		out, err := json.MarshalIndent(listDocument(matches), "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+	case OutputYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(listDocument(matches)); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case OutputName:
+		for _, match := range uniqueMatches(matches) {
+			_, err := fmt.Fprintf(w, "%s/%s\n", resourceGroup(match.RI.GVR), match.Object.GetName())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return ValidateOutputFormat(format)
+}
+
+func printTable(w io.Writer, matches []Match, wide bool) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	header := "IDENTIFIER\tNAMESPACE\tNAME\tGVR\tAGE"
+	if wide {
+		header += "\tCRITERIA"
+	}
+	fmt.Fprintln(tw, header)
+	now := time.Now()
+	for _, match := range matches {
+		row := fmt.Sprintf("%s\t%s\t%s\t%s\t%s",
+			orNone(match.RI.Name),
+			orNone(match.Object.GetNamespace()),
+			match.Object.GetName(),
+			formatGVR(match.RI.GVR),
+			formatAge(match.Object),
+		)
+		if wide {
+			row += "\t" + orNone(strings.Join(match.RI.criteriaSummary(match.expiredByTTL(now)), ","))
+		}
+		fmt.Fprintln(tw, row)
+	}
+	return tw.Flush()
+}
+
+// PrintChanges prints the changes that flag and delete made, or would have
+// made in a dry run, one row per object
+func PrintChanges(w io.Writer, changes []Change, dryRun DryRun) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(tw, "IDENTIFIER\tNAMESPACE\tNAME\tGVR\tCHANGE")
+	for _, change := range changes {
+		action := change.Action
+		if dryRun.Enabled() {
+			action += fmt.Sprintf(" (%s dry run)", dryRun)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			orNone(change.RI.Name),
+			orNone(change.Object.GetNamespace()),
+			change.Object.GetName(),
+			formatGVR(change.RI.GVR),
+			action,
+		)
+	}
+	return tw.Flush()
+}
+
+// PrintChangesByGVR prints the changes in one section per resource, in the
+// order that the resources first appear, with the number of objects that
+// were changed in the heading of every section
+func PrintChangesByGVR(w io.Writer, changes []Change, dryRun DryRun) error {
+	var gvrs []schema.GroupVersionResource
+	sections := map[schema.GroupVersionResource][]Change{}
+	for _, change := range changes {
+		if _, ok := sections[change.RI.GVR]; !ok {
+			gvrs = append(gvrs, change.RI.GVR)
+		}
+		sections[change.RI.GVR] = append(sections[change.RI.GVR], change)
+	}
+	suffix := ""
+	if dryRun.Enabled() {
+		suffix = fmt.Sprintf(" (%s dry run)", dryRun)
+	}
+	for i, gvr := range gvrs {
+		if i != 0 {
+			fmt.Fprintln(w)
+		}
+		objects := "objects"
+		if len(sections[gvr]) == 1 {
+			objects = "object"
+		}
+		fmt.Fprintf(w, "%s: %d %s%s\n", formatGVR(gvr), len(sections[gvr]), objects, suffix)
+		tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+		fmt.Fprintln(tw, "  NAMESPACE\tNAME\tIDENTIFIER\tCHANGE")
+		for _, change := range sections[gvr] {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n",
+				orNone(change.Object.GetNamespace()),
+				change.Object.GetName(),
+				orNone(change.RI.Name),
+				change.Action,
+			)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listDocument wraps the matched objects in a v1 List so that the output
+// can be piped into kubectl or jq. Objects selected by more than one
+// resource identifier only appear once.
+func listDocument(matches []Match) map[string]interface{} {
+	items := []interface{}{}
+	for _, match := range uniqueMatches(matches) {
+		items = append(items, match.Object.Object)
+	}
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"metadata":   map[string]interface{}{},
+		"items":      items,
+	}
+}
+
+func uniqueMatches(matches []Match) []Match {
+	var unique []Match
+	seen := map[string]bool{}
+	for _, match := range matches {
+		key := strings.Join([]string{formatGVR(match.RI.GVR), match.Object.GetNamespace(), match.Object.GetName()}, "/")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, match)
+	}
+	return unique
+}
+
+// criteriaSummary lists the criteria of the resource identifier. Since all
+// of them have to hold for an object to be selected, these are the criteria
+// that matched. Objects whose ttl or expires annotation expired are selected
+// without the criteria, the expression and the retention, so only the
+// selectors and the guards are listed for them.
+func (ri ResourceIdentifier) criteriaSummary(expiredByTTL bool) []string {
+	var summary []string
+	if ri.LabelSelector != "" {
+		summary = append(summary, "labelSelector="+ri.LabelSelector)
+	}
+	if ri.FieldSelector != "" {
+		summary = append(summary, "fieldSelector="+ri.FieldSelector)
+	}
+	if len(ri.Namespaces) != 0 {
+		summary = append(summary, "namespaces="+strings.Join(ri.Namespaces, "|"))
+	}
+	if len(ri.ExcludeNamespaces) != 0 {
+		summary = append(summary, "excludeNamespaces="+strings.Join(ri.ExcludeNamespaces, "|"))
+	}
+	if ri.NamespaceSelector != "" {
+		summary = append(summary, "namespaceSelector="+ri.NamespaceSelector)
+	}
+	if expiredByTTL {
+		summary = append(summary, "ttlAnnotations=expired")
+	} else {
+		summary = append(summary, ri.Criteria.summary()...)
+		if ri.Expression != "" {
+			summary = append(summary, "expression")
+		}
+	}
+	if ri.Unreferenced {
+		summary = append(summary, "unreferenced")
+	}
+	if ri.HonorTTLAnnotations && !expiredByTTL {
+		summary = append(summary, "ttlAnnotations")
+	}
+	if ri.Ownership != "" && ri.Ownership != OwnershipAny {
+		summary = append(summary, "ownership="+ri.Ownership)
+	}
+	if ri.OwnerKind != "" {
+		summary = append(summary, "ownerKind="+ri.OwnerKind)
+	}
+	if ri.Retain != nil && !expiredByTTL {
+		if ri.Retain.GroupBy != "" {
+			summary = append(summary, fmt.Sprintf("retain=%d(%s)", ri.Retain.Keep, ri.Retain.GroupBy))
+		} else {
+			summary = append(summary, fmt.Sprintf("retain=%d", ri.Retain.Keep))
+		}
+	}
+	return summary
+}
+
+func formatGVR(gvr schema.GroupVersionResource) string {
+	if gvr.Group == "" {
+		return gvr.Resource + "." + gvr.Version
+	}
+	return gvr.Resource + "." + gvr.Version + "." + gvr.Group
+}
+
+func resourceGroup(gvr schema.GroupVersionResource) string {
+	if gvr.Group == "" {
+		return gvr.Resource
+	}
+	return gvr.Resource + "." + gvr.Group
+}
+
+func formatAge(item unstructured.Unstructured) string {
+	created := item.GetCreationTimestamp()
+	if created.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(created.Time))
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
@@ -0,0 +1,122 @@
+package kln
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestPrintMatches(t *testing.T) {
+	ri := ResourceIdentifier{Name: "old-akinds", GVR: aGVRK.GVR, Criteria: Criteria{MinAge: 0.5, Status: map[string]interface{}{"foo": "bar"}}}
+	matches := []Match{{RI: ri, Object: *r1}, {RI: ri, Object: *r2}, {RI: ri, Object: *r2}}
+
+	t.Run("happy - table lists namespace, name, gvr and age per identifier", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := PrintMatches(&buf, OutputTable, matches); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 4 {
+			t.Fatalf("expected a header and 3 rows but got\n%s", buf.String())
+		}
+		if fields := strings.Fields(lines[0]); strings.Join(fields, " ") != "IDENTIFIER NAMESPACE NAME GVR AGE" {
+			t.Errorf("unexpected header %q", lines[0])
+		}
+		if fields := strings.Fields(lines[1]); strings.Join(fields[:4], " ") != "old-akinds ns name1 akinds.aversion.agroup" || fields[4] != "10m" {
+			t.Errorf("unexpected row %q", lines[1])
+		}
+	})
+
+	t.Run("happy - wide adds the criteria", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := PrintMatches(&buf, OutputWide, matches); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if !strings.HasSuffix(lines[0], "CRITERIA") || !strings.HasSuffix(lines[1], "minAge=0.5h,status.foo") {
+			t.Errorf("unexpected wide output\n%s", buf.String())
+		}
+	})
+
+	t.Run("happy - json is a List without duplicates", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := PrintMatches(&buf, OutputJSON, matches); err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got["kind"] != "List" || got["apiVersion"] != "v1" {
+			t.Errorf("expected a v1 List but got %s/%s", got["apiVersion"], got["kind"])
+		}
+		if items := got["items"].([]interface{}); len(items) != 2 {
+			t.Errorf("expected 2 items but got %d", len(items))
+		}
+	})
+
+	t.Run("happy - yaml is a List without duplicates", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := PrintMatches(&buf, OutputYAML, matches); err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]interface{}
+		if err := yaml.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if items := got["items"].([]interface{}); got["kind"] != "List" || len(items) != 2 {
+			t.Errorf("expected a List with 2 items but got\n%s", buf.String())
+		}
+	})
+
+	t.Run("happy - name prints resource.group/name", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := PrintMatches(&buf, OutputName, matches); err != nil {
+			t.Fatal(err)
+		}
+		want := "akinds.agroup/name1\nakinds.agroup/name2\n"
+		if buf.String() != want {
+			t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+		}
+	})
+
+	t.Run("sad - unknown format", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := PrintMatches(&buf, "xml", matches); err == nil {
+			t.Error("expected error but did not get any")
+		}
+	})
+}
+
+func TestPrintChangesByGVR(t *testing.T) {
+	ri := ResourceIdentifier{Name: "old-akinds", GVR: aGVRK.GVR}
+	changes := []Change{
+		{Match: Match{RI: ri, Object: *r1}, Action: "delete"},
+		{Match: Match{RI: ResourceIdentifier{GVR: fakeGVRK.GVR}, Object: *r3}, Action: "delete (unverified)"},
+		{Match: Match{RI: ri, Object: *r2}, Action: "delete"},
+	}
+	var buf bytes.Buffer
+	if err := PrintChangesByGVR(&buf, changes, DryRunClient); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		got = append(got, strings.Join(strings.Fields(line), " "))
+	}
+	want := []string{
+		"akinds.aversion.agroup: 2 objects (client dry run)",
+		"NAMESPACE NAME IDENTIFIER CHANGE",
+		"ns name1 old-akinds delete",
+		"ns name2 old-akinds delete",
+		"",
+		formatGVR(fakeGVRK.GVR) + ": 1 object (client dry run)",
+		"NAMESPACE NAME IDENTIFIER CHANGE",
+		"ns3 name3 <none> delete (unverified)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,133 @@
+package kln
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	OwnershipAny      = "any"
+	OwnershipOrphaned = "orphaned"
+	OwnershipOwned    = "owned"
+)
+
+func validateOwnership(ownership string) error {
+	switch ownership {
+	case "", OwnershipAny, OwnershipOrphaned, OwnershipOwned:
+		return nil
+	}
+	return fmt.Errorf("ownership must be one of %s|%s|%s but got %q", OwnershipOrphaned, OwnershipOwned, OwnershipAny, ownership)
+}
+
+// ownerResolver looks up owners through the dynamic client and remembers
+// whether they are still alive. The kinds of the owners are mapped to their
+// resources with the discovery information of the cluster.
+type ownerResolver struct {
+	client dynamic.Interface
+	mapper meta.RESTMapper
+	alive  map[types.UID]bool
+}
+
+func newOwnerResolver(client dynamic.Interface, mapper meta.RESTMapper) *ownerResolver {
+	return &ownerResolver{client: client, mapper: mapper, alive: map[types.UID]bool{}}
+}
+
+// isAlive reports whether the owner that ref points to still exists. An
+// owner that was recreated under the same name has a different uid and does
+// not count. Namespaced owners are looked up in the namespace of the object,
+// since an owner is either in the same namespace or cluster scoped. An owner
+// whose kind the cluster does not serve cannot be looked up, so the object
+// is skipped rather than taken for orphaned.
+func (r *ownerResolver) isAlive(namespace string, ref v1.OwnerReference) (bool, error) {
+	if alive, ok := r.alive[ref.UID]; ok {
+		return alive, nil
+	}
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return false, skipError{fmt.Sprintf("owner %s %s has an invalid apiVersion %q", ref.Kind, ref.Name, ref.APIVersion)}
+	}
+	if r.mapper == nil {
+		return false, errors.New("owners cannot be looked up before the resource identifier is resolved against the cluster")
+	}
+	mapping, err := r.mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: ref.Kind}, gv.Version)
+	if err != nil {
+		return false, skipError{fmt.Sprintf("cannot find the resource of owner %s %s: %s", ref.Kind, ref.Name, err)}
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		namespace = ""
+	}
+
+	alive := false
+	owner, err := r.client.Resource(mapping.Resource).Namespace(namespace).Get(context.TODO(), ref.Name, v1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, skipError{fmt.Sprintf("cannot look up owner %s %s: %s", ref.Kind, ref.Name, err)}
+	}
+	if err == nil {
+		alive = owner.GetUID() == ref.UID
+	}
+	r.alive[ref.UID] = alive
+	return alive, nil
+}
+
+// matchOwnership reports whether the object has the given ownership. Only
+// the owner references of ownerKind are considered when it is set. An owned
+// object has at least one live owner. An orphaned object has no live owner,
+// and with ownerKind it has to reference at least one owner of that kind.
+func (r *ownerResolver) matchOwnership(item unstructured.Unstructured, ownership, ownerKind string) (bool, error) {
+	var refs []v1.OwnerReference
+	for _, ref := range item.GetOwnerReferences() {
+		if ownerKind == "" || ref.Kind == ownerKind {
+			refs = append(refs, ref)
+		}
+	}
+
+	if ownership == "" || ownership == OwnershipAny {
+		return ownerKind == "" || len(refs) != 0, nil
+	}
+	if ownership == OwnershipOrphaned && ownerKind != "" && len(refs) == 0 {
+		return false, nil
+	}
+
+	hasLiveOwner := false
+	for _, ref := range refs {
+		alive, err := r.isAlive(item.GetNamespace(), ref)
+		if err != nil {
+			return false, err
+		}
+		if alive {
+			hasLiveOwner = true
+			break
+		}
+	}
+	return hasLiveOwner == (ownership == OwnershipOwned), nil
+}
+
+func filterByOwnership(client dynamic.Interface, mapper meta.RESTMapper, responseFromServer []unstructured.Unstructured, ownership, ownerKind string) ([]unstructured.Unstructured, error) {
+	var responseList []unstructured.Unstructured
+
+	resolver := newOwnerResolver(client, mapper)
+	for _, item := range responseFromServer {
+		match, err := resolver.matchOwnership(item, ownership, ownerKind)
+		var skip skipError
+		if errors.As(err, &skip) {
+			WarningLog.Printf("skipping %s/%s: %s", item.GetNamespace(), item.GetName(), skip)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			responseList = append(responseList, item)
+		}
+	}
+	return responseList, nil
+}
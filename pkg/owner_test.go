@@ -0,0 +1,135 @@
+package kln
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	discoveryfake "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/restmapper"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestListResourcesOwnership(t *testing.T) {
+	podGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		podGVR: "PodList",
+	})
+	create := func(gvr schema.GroupVersionResource, apiVersion, kind, namespace, name, uid string, owners ...v1.OwnerReference) {
+		item := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": apiVersion, "kind": kind}}
+		item.SetNamespace(namespace)
+		item.SetName(name)
+		item.SetUID(types.UID(uid))
+		item.SetOwnerReferences(owners)
+		if _, err := client.Resource(gvr).Namespace(namespace).Create(context.TODO(), item, v1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	owner := func(apiVersion, kind, name, uid string) v1.OwnerReference {
+		return v1.OwnerReference{APIVersion: apiVersion, Kind: kind, Name: name, UID: types.UID(uid)}
+	}
+
+	create(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}, "apps/v1", "ReplicaSet", "web", "web-1", "rs-1")
+	create(schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}, "batch/v1", "Job", "web", "backup", "job-1")
+	create(schema.GroupVersionResource{Version: "v1", Resource: "nodes"}, "v1", "Node", "", "node-1", "node-1")
+	create(podGVR, "v1", "Pod", "web", "web-1-a", "pod-1", owner("apps/v1", "ReplicaSet", "web-1", "rs-1"))
+	create(podGVR, "v1", "Pod", "web", "old-a", "pod-2", owner("apps/v1", "ReplicaSet", "old", "rs-0"))
+	create(podGVR, "v1", "Pod", "web", "recreated-a", "pod-3", owner("apps/v1", "ReplicaSet", "web-1", "rs-previous"))
+	create(podGVR, "v1", "Pod", "web", "bare", "pod-4")
+	create(podGVR, "v1", "Pod", "web", "backup-a", "pod-5", owner("batch/v1", "Job", "backup", "job-1"))
+	create(podGVR, "v1", "Pod", "web", "mirror", "pod-6", owner("v1", "Node", "node-1", "node-1"))
+	create(podGVR, "v1", "Pod", "web", "invalid", "pod-7", owner("apps/v1/beta", "ReplicaSet", "web-1", "rs-1"))
+	// the resource of Endpoints is endpoints, not the guessed endpointses
+	create(schema.GroupVersionResource{Version: "v1", Resource: "endpoints"}, "v1", "Endpoints", "web", "web", "ep-1")
+	create(podGVR, "v1", "Pod", "web", "endpoints-a", "pod-8", owner("v1", "Endpoints", "web", "ep-1"))
+	create(podGVR, "v1", "Pod", "web", "widget-a", "pod-9", owner("example.com/v1", "Widget", "w", "widget-1"))
+
+	groups, err := restmapper.GetAPIGroupResources(&discoveryfake.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*v1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []v1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true},
+				{Name: "endpoints", Kind: "Endpoints", Namespaced: true},
+				{Name: "nodes", Kind: "Node"},
+			},
+		},
+		{GroupVersion: "apps/v1", APIResources: []v1.APIResource{{Name: "replicasets", Kind: "ReplicaSet", Namespaced: true}}},
+		{GroupVersion: "batch/v1", APIResources: []v1.APIResource{{Name: "jobs", Kind: "Job", Namespaced: true}}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapper := restmapper.NewDiscoveryRESTMapper(groups)
+
+	ownershipTests := []struct {
+		name       string
+		ri         ResourceIdentifier
+		unresolved bool
+		want       []string
+		wantError  bool
+	}{
+		{
+			name: "happy - orphaned objects have no owner or only dead owners",
+			ri:   ResourceIdentifier{GVR: podGVR, Ownership: OwnershipOrphaned},
+			want: []string{"bare", "old-a", "recreated-a"},
+		},
+		{
+			name: "happy - owned objects have a live owner",
+			ri:   ResourceIdentifier{GVR: podGVR, Ownership: OwnershipOwned},
+			want: []string{"backup-a", "endpoints-a", "mirror", "web-1-a"},
+		},
+		{
+			name: "happy - owned by a kind",
+			ri:   ResourceIdentifier{GVR: podGVR, Ownership: OwnershipOwned, OwnerKind: "Job"},
+			want: []string{"backup-a"},
+		},
+		{
+			name: "happy - orphaned from a kind",
+			ri:   ResourceIdentifier{GVR: podGVR, Ownership: OwnershipOrphaned, OwnerKind: "ReplicaSet"},
+			want: []string{"old-a", "recreated-a"},
+		},
+		{
+			name: "happy - any ownership by a kind",
+			ri:   ResourceIdentifier{GVR: podGVR, Ownership: OwnershipAny, OwnerKind: "ReplicaSet"},
+			want: []string{"invalid", "old-a", "recreated-a", "web-1-a"},
+		},
+		{
+			name:       "sad - owners are not looked up without the discovery information",
+			ri:         ResourceIdentifier{GVR: podGVR, Ownership: OwnershipOrphaned},
+			unresolved: true,
+			wantError:  true,
+		},
+		{
+			name:      "sad - unknown ownership",
+			ri:        ResourceIdentifier{GVR: podGVR, Ownership: "adopted"},
+			wantError: true,
+		},
+	}
+
+	for _, tc := range ownershipTests {
+		t.Run(tc.name, func(t *testing.T) {
+			if !tc.unresolved {
+				tc.ri.mapper = mapper
+			}
+			got, err := ListResources(client, tc.ri)
+			if tc.wantError != (err != nil) {
+				t.Errorf("got error %v, want error %v", err, tc.wantError)
+			}
+			var names []string
+			for _, item := range got {
+				names = append(names, item.GetName())
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("got %v, want %v", names, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package kln
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	PlanAPIVersion = PolicyGroup + "/" + PolicyVersion
+	PlanKind       = "Plan"
+)
+
+// Plan is the list of objects that kln is going to delete, so that it can be
+// reviewed before it is applied. Every object is pinned by its UID, so that
+// an object that was deleted and created again with the same name is not
+// deleted. The safety policy that was in effect when the plan was made is
+// applied again.
+type Plan struct {
+	APIVersion string          `json:"apiVersion"`
+	Kind       string          `json:"kind"`
+	CreatedAt  string          `json:"createdAt"`
+	Safety     SafetyPolicy    `json:"safety"`
+	Objects    []PlannedObject `json:"objects"`
+}
+
+// PlannedObject is an object of a plan along with the resource identifier
+// that selected it
+type PlannedObject struct {
+	Group              string    `json:"group,omitempty"`
+	Version            string    `json:"version"`
+	Resource           string    `json:"resource"`
+	Namespace          string    `json:"namespace,omitempty"`
+	Name               string    `json:"name"`
+	UID                types.UID `json:"uid"`
+	ResourceVersion    string    `json:"resourceVersion"`
+	ResourceIdentifier string    `json:"resourceIdentifier,omitempty"`
+	Source             string    `json:"source,omitempty"`
+}
+
+// NewPlan plans the deletion of the matched objects. Objects that were
+// selected by more than one resource identifier are only planned once.
+func NewPlan(matches []Match) Plan {
+	plan := Plan{
+		APIVersion: PlanAPIVersion,
+		Kind:       PlanKind,
+		CreatedAt:  time.Now().UTC().Format(RFC3339),
+		Safety:     Safety,
+		Objects:    []PlannedObject{},
+	}
+	for _, match := range uniqueMatches(matches) {
+		plan.Objects = append(plan.Objects, PlannedObject{
+			Group:              match.RI.GVR.Group,
+			Version:            match.RI.GVR.Version,
+			Resource:           match.RI.GVR.Resource,
+			Namespace:          match.Object.GetNamespace(),
+			Name:               match.Object.GetName(),
+			UID:                match.Object.GetUID(),
+			ResourceVersion:    match.Object.GetResourceVersion(),
+			ResourceIdentifier: match.RI.Name,
+			Source:             match.RI.Source,
+		})
+	}
+	return plan
+}
+
+func WritePlan(w io.Writer, plan Plan) error {
+	out, err := json.MarshalIndent(plan, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+func ReadPlan(file string) (Plan, error) {
+	var plan Plan
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return plan, err
+	}
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return plan, fmt.Errorf("%s: %w", file, err)
+	}
+	if plan.Kind != PlanKind || plan.APIVersion != PlanAPIVersion {
+		return plan, fmt.Errorf("%s: not a kln plan, expected %s %s", file, PlanAPIVersion, PlanKind)
+	}
+	if err := plan.Safety.Validate(); err != nil {
+		return plan, fmt.Errorf("%s: %w", file, err)
+	}
+	for i, object := range plan.Objects {
+		if object.Version == "" || object.Resource == "" || object.Name == "" || object.UID == "" {
+			return plan, fmt.Errorf("%s: objects[%d]: version, resource, name and uid are required", file, i)
+		}
+	}
+	return plan, nil
+}
+
+func (o PlannedObject) gvr() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: o.Group, Version: o.Version, Resource: o.Resource}
+}
+
+// ApplyPlan deletes the objects of the plan. Objects that no longer exist,
+// that were created again since the plan was made or that the safety policy
+// of the plan protects are skipped and reported. With checkResourceVersion,
+// objects that changed in any way since the plan was made are skipped too.
+// The deletions are made with preconditions, so an object that changes
+// between the check and the deletion is not deleted either. It returns the
+// number of objects that were deleted.
+func ApplyPlan(client dynamic.Interface, plan Plan, checkResourceVersion bool) (int, error) {
+	deleted := 0
+	for _, object := range plan.Objects {
+		resource := client.Resource(object.gvr()).Namespace(object.Namespace)
+		item, err := resource.Get(context.TODO(), object.Name, v1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			WarningLog.Printf("skipping %s/%s: it no longer exists", object.Namespace, object.Name)
+			continue
+		}
+		if err != nil {
+			return deleted, err
+		}
+		if item.GetUID() != object.UID {
+			WarningLog.Printf("skipping %s/%s: it was created again since the plan was made", object.Namespace, object.Name)
+			continue
+		}
+		if checkResourceVersion && item.GetResourceVersion() != object.ResourceVersion {
+			WarningLog.Printf("skipping %s/%s: it changed since the plan was made", object.Namespace, object.Name)
+			continue
+		}
+		if reason := plan.Safety.Check(*item); reason != "" {
+			WarningLog.Printf("skipping %s/%s: %s", object.Namespace, object.Name, reason)
+			continue
+		}
+
+		preconditions := v1.Preconditions{UID: &object.UID}
+		if checkResourceVersion {
+			preconditions.ResourceVersion = &object.ResourceVersion
+		}
+		err = resource.Delete(context.TODO(), object.Name, v1.DeleteOptions{Preconditions: &preconditions})
+		if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+			WarningLog.Printf("skipping %s/%s: it changed since the plan was made", object.Namespace, object.Name)
+			continue
+		}
+		if err != nil {
+			return deleted, err
+		}
+		InfoLog.Printf("deleted %s %s/%s", resourceGroup(object.gvr()), object.Namespace, object.Name)
+		deleted++
+	}
+	return deleted, nil
+}
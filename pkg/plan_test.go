@@ -0,0 +1,114 @@
+package kln
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestPlan(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		aGVRK.GVR: aGVRK.Kind + "List",
+	})
+	resource := client.Resource(aGVRK.GVR).Namespace("preview")
+	create := func(name, uid, resourceVersion string, annotations map[string]string) *unstructured.Unstructured {
+		item := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": aGVRK.GVR.Group + "/" + aGVRK.GVR.Version, "kind": aGVRK.Kind}}
+		item.SetNamespace("preview")
+		item.SetName(name)
+		item.SetUID(types.UID(uid))
+		item.SetResourceVersion(resourceVersion)
+		item.SetAnnotations(annotations)
+		created, err := resource.Create(context.TODO(), item, v1.CreateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return created
+	}
+	ri := ResourceIdentifier{Name: "previews", Source: "kln.yaml", GVR: aGVRK.GVR}
+	var matches []Match
+	for _, name := range []string{"unchanged", "changed", "recreated", "gone", "protected"} {
+		matches = append(matches, Match{RI: ri, Object: *create(name, name+"-uid", "1", nil)})
+	}
+	matches = append(matches, matches[0])
+
+	plan := NewPlan(matches)
+	if len(plan.Objects) != 5 {
+		t.Fatalf("expected 5 planned objects but got %d", len(plan.Objects))
+	}
+	if object := plan.Objects[0]; object != (PlannedObject{
+		Group: "agroup", Version: "aversion", Resource: "akinds", Namespace: "preview", Name: "unchanged",
+		UID: "unchanged-uid", ResourceVersion: "1", ResourceIdentifier: "previews", Source: "kln.yaml",
+	}) {
+		t.Errorf("unexpected planned object %+v", object)
+	}
+
+	file := filepath.Join(t.TempDir(), "plan.json")
+	out, _ := os.Create(file)
+	if err := WritePlan(out, plan); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+	plan, err := ReadPlan(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Objects) != 5 || strings.Join(plan.Safety.ProtectedNamespaces, ",") != strings.Join(SystemNamespaces, ",") {
+		t.Errorf("expected the plan to survive the round trip but got %+v", plan)
+	}
+
+	changed, _ := resource.Get(context.TODO(), "changed", v1.GetOptions{})
+	changed.SetResourceVersion("2")
+	resource.Update(context.TODO(), changed, v1.UpdateOptions{})
+	resource.Delete(context.TODO(), "recreated", v1.DeleteOptions{})
+	create("recreated", "other-uid", "3", nil)
+	resource.Delete(context.TODO(), "gone", v1.DeleteOptions{})
+	protected, _ := resource.Get(context.TODO(), "protected", v1.GetOptions{})
+	protected.SetAnnotations(map[string]string{ProtectAnnotation: "true"})
+	resource.Update(context.TODO(), protected, v1.UpdateOptions{})
+
+	remaining := func() string {
+		list, _ := resource.List(context.TODO(), v1.ListOptions{})
+		var names []string
+		for _, item := range list.Items {
+			names = append(names, item.GetName())
+		}
+		sort.Strings(names)
+		return strings.Join(names, ",")
+	}
+
+	t.Run("happy - changed objects are deleted unless resource versions are checked", func(t *testing.T) {
+		deleted, err := ApplyPlan(client, plan, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted != 1 || remaining() != "changed,protected,recreated" {
+			t.Errorf("expected only unchanged to be deleted but %d were and %s remain", deleted, remaining())
+		}
+
+		deleted, err = ApplyPlan(client, plan, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted != 1 || remaining() != "protected,recreated" {
+			t.Errorf("expected changed to be deleted but %d were and %s remain", deleted, remaining())
+		}
+	})
+
+	t.Run("sad - not a plan", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "list.json")
+		os.WriteFile(file, []byte(`{"apiVersion": "v1", "kind": "List", "items": []}`), 0o644)
+		if _, err := ReadPlan(file); err == nil {
+			t.Error("expected error but did not get any")
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package kln
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// CleanupPolicies and ClusterCleanupPolicies keep resource identifiers in the
+// cluster instead of in a local file. Their spec is a resource identifier.
+// A CleanupPolicy only selects objects in its own namespace, so that teams
+// can own the policies of their namespaces.
+const (
+	PolicyGroup   = "kln.com"
+	PolicyVersion = "v1alpha1"
+
+	CleanupPolicyKind        = "CleanupPolicy"
+	ClusterCleanupPolicyKind = "ClusterCleanupPolicy"
+)
+
+var cleanupPolicyGVR = schema.GroupVersionResource{Group: PolicyGroup, Version: PolicyVersion, Resource: "cleanuppolicies"}
+var clusterCleanupPolicyGVR = schema.GroupVersionResource{Group: PolicyGroup, Version: PolicyVersion, Resource: "clustercleanuppolicies"}
+
+// LoadClusterConfig reads the ClusterCleanupPolicies and the CleanupPolicies
+// of every namespace and validates them the same way as ParseConfig
+// validates a resource identifier file
+func LoadClusterConfig(client dynamic.Interface) (*Config, error) {
+	config := &Config{file: "cluster"}
+	var errs ValidationErrors
+	for _, gvr := range []schema.GroupVersionResource{clusterCleanupPolicyGVR, cleanupPolicyGVR} {
+		response, err := client.Resource(gvr).List(context.TODO(), v1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("could not list %s: %w", resourceGroup(gvr), err)
+		}
+		policies := response.Items
+		sort.Slice(policies, func(i, j int) bool {
+			if policies[i].GetNamespace() != policies[j].GetNamespace() {
+				return policies[i].GetNamespace() < policies[j].GetNamespace()
+			}
+			return policies[i].GetName() < policies[j].GetName()
+		})
+		for _, policy := range policies {
+			errs = append(errs, config.addPolicy(policy)...)
+		}
+	}
+	if len(errs) != 0 {
+		return nil, errs
+	}
+	if len(config.Items) == 0 {
+		return nil, ValidationErrors{{File: config.file, Message: "no cleanup policies"}}
+	}
+	return config, nil
+}
+
+// addPolicy decodes the spec of the policy into a resource identifier and
+// adds it to the configuration. Resource identifiers without a name are
+// named after their policy.
+func (c *Config) addPolicy(policy unstructured.Unstructured) ValidationErrors {
+	source := itemSource{file: fmt.Sprintf("%s %s", policy.GetKind(), policy.GetName()), path: "spec"}
+	if policy.GetNamespace() != "" {
+		source.file = fmt.Sprintf("%s %s/%s", policy.GetKind(), policy.GetNamespace(), policy.GetName())
+	}
+	spec, _, err := unstructured.NestedMap(policy.Object, "spec")
+	if err != nil {
+		return ValidationErrors{{File: source.file, Path: "spec", Message: err.Error()}}
+	}
+	source.node = &yaml.Node{}
+	if err := source.node.Encode(spec); err != nil {
+		return ValidationErrors{{File: source.file, Path: "spec", Message: err.Error()}}
+	}
+
+	checker := schemaChecker{file: source.file}
+	checker.check(source.node, reflect.TypeOf(ResourceIdentifier{}), source.path)
+	if len(checker.errs) != 0 {
+		return checker.errs
+	}
+	var ri ResourceIdentifier
+	if err := source.node.Decode(&ri); err != nil {
+		return ValidationErrors{{File: source.file, Path: "spec", Message: err.Error()}}
+	}
+	if ri.Name == "" {
+		ri.Name = policy.GetName()
+	}
+	ri.Source = source.file
+
+	c.Items = append(c.Items, ri)
+	c.sources = append(c.sources, source)
+	i := len(c.Items) - 1
+	errs := c.validateItem(i)
+	if namespace := policy.GetNamespace(); namespace != "" {
+		if ri.NamespaceSelector != "" || len(ri.ExcludeNamespaces) != 0 ||
+			len(ri.Namespaces) > 1 || (len(ri.Namespaces) == 1 && ri.Namespaces[0] != namespace) {
+			errs = append(errs, c.itemError(i, "", fmt.Sprintf("a %s can only select objects in its own namespace", CleanupPolicyKind)))
+		}
+		c.Items[i].Namespaces = []string{namespace}
+	}
+	return errs
+}
@@ -0,0 +1,100 @@
+package kln
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newPolicy(kind, namespace, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": PolicyGroup + "/" + PolicyVersion,
+		"kind":       kind,
+		"spec":       spec,
+	}}
+	policy.SetNamespace(namespace)
+	policy.SetName(name)
+	return policy
+}
+
+func newPolicyClient(t *testing.T, policies ...*unstructured.Unstructured) *dynamicfake.FakeDynamicClient {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		cleanupPolicyGVR:        CleanupPolicyKind + "List",
+		clusterCleanupPolicyGVR: ClusterCleanupPolicyKind + "List",
+	})
+	for _, policy := range policies {
+		gvr := cleanupPolicyGVR
+		if policy.GetKind() == ClusterCleanupPolicyKind {
+			gvr = clusterCleanupPolicyGVR
+		}
+		if _, err := client.Resource(gvr).Namespace(policy.GetNamespace()).Create(context.TODO(), policy, v1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return client
+}
+
+func TestLoadClusterConfig(t *testing.T) {
+	jobs := map[string]interface{}{"group": "batch", "version": "v1", "resource": "jobs"}
+
+	t.Run("happy - cluster policies first, namespaced policies stay in their namespace", func(t *testing.T) {
+		client := newPolicyClient(t,
+			newPolicy(CleanupPolicyKind, "team-b", "old-jobs", map[string]interface{}{"gvr": jobs, "minAge": "3d"}),
+			newPolicy(CleanupPolicyKind, "team-a", "old-jobs", map[string]interface{}{"gvr": jobs, "minAge": int64(1), "namespaces": []interface{}{"team-a"}}),
+			newPolicy(ClusterCleanupPolicyKind, "", "completed-jobs", map[string]interface{}{
+				"name":       "completed",
+				"gvr":        jobs,
+				"status":     map[string]interface{}{"succeeded": int64(1)},
+				"expression": "age > duration('1h')",
+			}),
+		)
+		config, err := LoadClusterConfig(client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, ri := range config.Items {
+			got = append(got, ri.Name+":"+strings.Join(ri.Namespaces, ","))
+		}
+		if strings.Join(got, " ") != "completed: old-jobs:team-a old-jobs:team-b" {
+			t.Errorf("unexpected resource identifiers %v", got)
+		}
+		if config.Items[0].program == nil || config.Items[2].MinAge != 72 {
+			t.Errorf("expected the policies to be compiled and decoded but got %+v", config.Items)
+		}
+	})
+
+	t.Run("sad - invalid policies are reported by name", func(t *testing.T) {
+		client := newPolicyClient(t,
+			newPolicy(CleanupPolicyKind, "team-a", "typo", map[string]interface{}{"gvr": jobs, "minage": int64(1)}),
+			newPolicy(CleanupPolicyKind, "team-a", "other-namespace", map[string]interface{}{"gvr": jobs, "minAge": int64(1), "namespaces": []interface{}{"team-b"}}),
+			newPolicy(CleanupPolicyKind, "team-a", "everything", map[string]interface{}{"gvr": jobs}),
+			newPolicy(ClusterCleanupPolicyKind, "", "negative", map[string]interface{}{"gvr": jobs, "maxAge": "-1h"}),
+		)
+		_, err := LoadClusterConfig(client)
+		if err == nil {
+			t.Fatal("expected error but did not get any")
+		}
+		want := []string{
+			`ClusterCleanupPolicy negative: spec.maxAge: age cannot be negative`,
+			`CleanupPolicy team-a/everything: spec: selects every object of jobs.v1.batch, set at least one criterion`,
+			`CleanupPolicy team-a/other-namespace: spec: a CleanupPolicy can only select objects in its own namespace`,
+			`CleanupPolicy team-a/typo: spec.minage: unknown field "minage", did you mean "minAge"?`,
+		}
+		if err.Error() != strings.Join(want, "\n") {
+			t.Errorf("got\n%s\nwant\n%s", err, strings.Join(want, "\n"))
+		}
+	})
+
+	t.Run("sad - no policies", func(t *testing.T) {
+		if _, err := LoadClusterConfig(newPolicyClient(t)); err == nil {
+			t.Error("expected error but did not get any")
+		}
+	})
+}
@@ -0,0 +1,207 @@
+package kln
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	configMaps             = "configmaps"
+	secrets                = "secrets"
+	persistentVolumeClaims = "persistentvolumeclaims"
+	serviceAccounts        = "serviceaccounts"
+
+	serviceAccountTokenType      = "kubernetes.io/service-account-token"
+	serviceAccountNameAnnotation = "kubernetes.io/service-account.name"
+)
+
+// referrers are the resources whose objects reference config maps, secrets,
+// persistent volume claims and service accounts, with the paths to their pod
+// specs
+var referrers = []struct {
+	gvr     schema.GroupVersionResource
+	podSpec []string
+}{
+	{schema.GroupVersionResource{Version: "v1", Resource: "pods"}, []string{"spec"}},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, []string{"spec", "template", "spec"}},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}, []string{"spec", "template", "spec"}},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}, []string{"spec", "template", "spec"}},
+	{schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}, []string{"spec", "template", "spec"}},
+	{schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "cronjobs"}, []string{"spec", "jobTemplate", "spec", "template", "spec"}},
+	{schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}, nil},
+	{schema.GroupVersionResource{Version: "v1", Resource: serviceAccounts}, nil},
+}
+
+func validateUnreferenced(gvr schema.GroupVersionResource) error {
+	if gvr.Group == "" {
+		switch gvr.Resource {
+		case configMaps, secrets, persistentVolumeClaims, serviceAccounts:
+			return nil
+		}
+	}
+	return fmt.Errorf("unreferenced is only supported for %s, %s, %s and %s", configMaps, secrets, persistentVolumeClaims, serviceAccounts)
+}
+
+// referenceIndex holds the config maps, secrets, persistent volume claims
+// and service accounts that are referenced in every namespace
+type referenceIndex struct {
+	refs map[string]bool
+	// claimPrefixes are the name prefixes of the persistent volume claims
+	// created from the volumeClaimTemplates of stateful sets
+	claimPrefixes map[string][]string
+	// serviceAccounts are the service accounts that exist, which keep the
+	// token secrets bound to them
+	serviceAccounts map[string]bool
+}
+
+func referenceKey(resource, namespace, name string) string {
+	return resource + "/" + namespace + "/" + name
+}
+
+func buildReferenceIndex(client dynamic.Interface) (*referenceIndex, error) {
+	index := &referenceIndex{refs: map[string]bool{}, claimPrefixes: map[string][]string{}, serviceAccounts: map[string]bool{}}
+	for _, referrer := range referrers {
+		list, err := client.Resource(referrer.gvr).List(context.TODO(), v1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("cannot build the reference index from %s: %w", referrer.gvr.Resource, err)
+		}
+		for _, item := range list.Items {
+			namespace := item.GetNamespace()
+			switch referrer.gvr.Resource {
+			case "ingresses":
+				tls, _, _ := unstructured.NestedSlice(item.Object, "spec", "tls")
+				for _, entry := range tls {
+					index.add(secrets, namespace, entry, "secretName")
+				}
+			case serviceAccounts:
+				index.serviceAccounts[referenceKey(serviceAccounts, namespace, item.GetName())] = true
+				for _, field := range []string{"secrets", "imagePullSecrets"} {
+					refs, _, _ := unstructured.NestedSlice(item.Object, field)
+					for _, ref := range refs {
+						index.add(secrets, namespace, ref, "name")
+					}
+				}
+			case "statefulsets":
+				templates, _, _ := unstructured.NestedSlice(item.Object, "spec", "volumeClaimTemplates")
+				for _, template := range templates {
+					name, _, _ := unstructured.NestedString(asMap(template), "metadata", "name")
+					prefix := name + "-" + item.GetName() + "-"
+					index.claimPrefixes[namespace] = append(index.claimPrefixes[namespace], prefix)
+				}
+			}
+			if referrer.podSpec != nil {
+				podSpec, found, _ := unstructured.NestedMap(item.Object, referrer.podSpec...)
+				if found {
+					index.addPodSpec(namespace, podSpec)
+				}
+			}
+		}
+	}
+	return index, nil
+}
+
+func (index *referenceIndex) addPodSpec(namespace string, podSpec map[string]interface{}) {
+	serviceAccount, _, _ := unstructured.NestedString(podSpec, "serviceAccountName")
+	if serviceAccount == "" {
+		serviceAccount, _, _ = unstructured.NestedString(podSpec, "serviceAccount")
+	}
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	index.refs[referenceKey(serviceAccounts, namespace, serviceAccount)] = true
+
+	pullSecrets, _, _ := unstructured.NestedSlice(podSpec, "imagePullSecrets")
+	for _, ref := range pullSecrets {
+		index.add(secrets, namespace, ref, "name")
+	}
+
+	volumes, _, _ := unstructured.NestedSlice(podSpec, "volumes")
+	for _, volume := range volumes {
+		index.add(configMaps, namespace, volume, "configMap", "name")
+		index.add(secrets, namespace, volume, "secret", "secretName")
+		index.add(persistentVolumeClaims, namespace, volume, "persistentVolumeClaim", "claimName")
+		sources, _, _ := unstructured.NestedSlice(asMap(volume), "projected", "sources")
+		for _, source := range sources {
+			index.add(configMaps, namespace, source, "configMap", "name")
+			index.add(secrets, namespace, source, "secret", "name")
+		}
+	}
+
+	for _, containerField := range []string{"containers", "initContainers", "ephemeralContainers"} {
+		containers, _, _ := unstructured.NestedSlice(podSpec, containerField)
+		for _, container := range containers {
+			env, _, _ := unstructured.NestedSlice(asMap(container), "env")
+			for _, variable := range env {
+				index.add(configMaps, namespace, variable, "valueFrom", "configMapKeyRef", "name")
+				index.add(secrets, namespace, variable, "valueFrom", "secretKeyRef", "name")
+			}
+			envFrom, _, _ := unstructured.NestedSlice(asMap(container), "envFrom")
+			for _, source := range envFrom {
+				index.add(configMaps, namespace, source, "configMapRef", "name")
+				index.add(secrets, namespace, source, "secretRef", "name")
+			}
+		}
+	}
+}
+
+// add records the name at fields of object as a reference to resource
+func (index *referenceIndex) add(resource, namespace string, object interface{}, fields ...string) {
+	name, found, _ := unstructured.NestedString(asMap(object), fields...)
+	if found && name != "" {
+		index.refs[referenceKey(resource, namespace, name)] = true
+	}
+}
+
+func (index *referenceIndex) isReferenced(resource string, item unstructured.Unstructured) bool {
+	namespace := item.GetNamespace()
+	if index.refs[referenceKey(resource, namespace, item.GetName())] {
+		return true
+	}
+	if resource == secrets && isServiceAccountToken(item) {
+		name := item.GetAnnotations()[serviceAccountNameAnnotation]
+		if index.serviceAccounts[referenceKey(serviceAccounts, namespace, name)] {
+			return true
+		}
+	}
+	if resource == persistentVolumeClaims {
+		for _, prefix := range index.claimPrefixes[namespace] {
+			if strings.HasPrefix(item.GetName(), prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isServiceAccountToken tells whether the secret holds the token of the
+// service account named in its annotations
+func isServiceAccountToken(item unstructured.Unstructured) bool {
+	secretType, _, _ := unstructured.NestedString(item.Object, "type")
+	return secretType == serviceAccountTokenType
+}
+
+func filterByReferences(client dynamic.Interface, responseFromServer []unstructured.Unstructured, resource string) ([]unstructured.Unstructured, error) {
+	var responseList []unstructured.Unstructured
+
+	index, err := buildReferenceIndex(client)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range responseFromServer {
+		if !index.isReferenced(resource, item) {
+			responseList = append(responseList, item)
+		}
+	}
+	return responseList, nil
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
@@ -0,0 +1,133 @@
+package kln
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestListResourcesUnreferenced(t *testing.T) {
+	core := func(resource string) schema.GroupVersionResource {
+		return schema.GroupVersionResource{Version: "v1", Resource: resource}
+	}
+	listKinds := map[schema.GroupVersionResource]string{
+		core(configMaps):             "ConfigMapList",
+		core(secrets):                "SecretList",
+		core(persistentVolumeClaims): "PersistentVolumeClaimList",
+	}
+	for _, referrer := range referrers {
+		listKinds[referrer.gvr] = "List"
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds)
+
+	create := func(gvr schema.GroupVersionResource, object string) {
+		var item unstructured.Unstructured
+		if err := item.UnmarshalJSON([]byte(object)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Resource(gvr).Namespace(item.GetNamespace()).Create(context.TODO(), &item, v1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	named := func(gvr schema.GroupVersionResource, kind, namespace string, names ...string) {
+		for _, name := range names {
+			create(gvr, `{"apiVersion": "v1", "kind": "`+kind+`", "metadata": {"namespace": "`+namespace+`", "name": "`+name+`"}}`)
+		}
+	}
+
+	named(core(configMaps), "ConfigMap", "app", "cm-volume", "cm-env", "cm-envfrom", "cm-projected", "cm-unused")
+	named(core(secrets), "Secret", "app", "s-volume", "s-tls", "s-pull", "s-sa", "s-job", "s-unused")
+	named(core(secrets), "Secret", "other", "s-volume")
+	named(core(persistentVolumeClaims), "PersistentVolumeClaim", "app", "data-db-0", "data-db-3", "pvc-pod", "pvc-unused")
+	named(core(serviceAccounts), "ServiceAccount", "app", "default", "builder", "sa-unused")
+	named(core(serviceAccounts), "ServiceAccount", "other", "default")
+	create(core(serviceAccounts), `{"apiVersion": "v1", "kind": "ServiceAccount", "metadata": {"namespace": "app", "name": "deployer"},
+		"secrets": [{"name": "s-sa"}]}`)
+	token := func(namespace, name, serviceAccount string) {
+		create(core(secrets), `{"apiVersion": "v1", "kind": "Secret", "type": "kubernetes.io/service-account-token",
+			"metadata": {"namespace": "`+namespace+`", "name": "`+name+`", "annotations": {"kubernetes.io/service-account.name": "`+serviceAccount+`"}}}`)
+	}
+	token("app", "s-token-builder", "builder")
+	token("app", "s-token-removed", "removed")
+	token("other", "s-token-builder", "builder")
+
+	create(referrers[0].gvr, `{"apiVersion": "v1", "kind": "Pod", "metadata": {"namespace": "app", "name": "web-a"},
+		"spec": {"serviceAccountName": "builder",
+			"containers": [{"name": "web", "env": [{"name": "MODE", "valueFrom": {"configMapKeyRef": {"name": "cm-env", "key": "mode"}}}]}],
+			"volumes": [{"name": "data", "persistentVolumeClaim": {"claimName": "pvc-pod"}}]}}`)
+	create(referrers[1].gvr, `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"namespace": "app", "name": "web"},
+		"spec": {"template": {"spec": {"volumes": [{"name": "config", "configMap": {"name": "cm-volume"}}, {"name": "certs", "secret": {"secretName": "s-volume"}}]}}}}`)
+	create(referrers[2].gvr, `{"apiVersion": "apps/v1", "kind": "StatefulSet", "metadata": {"namespace": "app", "name": "db"},
+		"spec": {"volumeClaimTemplates": [{"metadata": {"name": "data"}}],
+			"template": {"spec": {"volumes": [{"name": "all", "projected": {"sources": [{"configMap": {"name": "cm-projected"}}]}}]}}}}`)
+	create(referrers[3].gvr, `{"apiVersion": "apps/v1", "kind": "DaemonSet", "metadata": {"namespace": "app", "name": "agent"},
+		"spec": {"template": {"spec": {"imagePullSecrets": [{"name": "s-pull"}]}}}}`)
+	create(referrers[4].gvr, `{"apiVersion": "batch/v1", "kind": "Job", "metadata": {"namespace": "app", "name": "migrate"},
+		"spec": {"template": {"spec": {"initContainers": [{"name": "migrate", "env": [{"name": "TOKEN", "valueFrom": {"secretKeyRef": {"name": "s-job", "key": "token"}}}]}]}}}}`)
+	create(referrers[5].gvr, `{"apiVersion": "batch/v1", "kind": "CronJob", "metadata": {"namespace": "app", "name": "report"},
+		"spec": {"jobTemplate": {"spec": {"template": {"spec": {"containers": [{"name": "report", "envFrom": [{"configMapRef": {"name": "cm-envfrom"}}]}]}}}}}}`)
+	create(referrers[6].gvr, `{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress", "metadata": {"namespace": "app", "name": "web"},
+		"spec": {"tls": [{"hosts": ["example.com"], "secretName": "s-tls"}]}}`)
+
+	unreferencedTests := []struct {
+		name      string
+		ri        ResourceIdentifier
+		want      []string
+		wantError bool
+	}{
+		{
+			name: "happy - config maps",
+			ri:   ResourceIdentifier{GVR: core(configMaps), Unreferenced: true},
+			want: []string{"app/cm-unused"},
+		},
+		{
+			name: "happy - secrets and service account tokens are matched per namespace",
+			ri:   ResourceIdentifier{GVR: core(secrets), Unreferenced: true},
+			want: []string{"app/s-token-removed", "app/s-unused", "other/s-token-builder", "other/s-volume"},
+		},
+		{
+			name: "happy - persistent volume claims of stateful sets are referenced",
+			ri:   ResourceIdentifier{GVR: core(persistentVolumeClaims), Unreferenced: true},
+			want: []string{"app/pvc-unused"},
+		},
+		{
+			name: "happy - default service accounts are referenced by pods without one",
+			ri:   ResourceIdentifier{GVR: core(serviceAccounts), Unreferenced: true},
+			want: []string{"app/deployer", "app/sa-unused", "other/default"},
+		},
+		{
+			name: "happy - combined with criteria",
+			ri:   ResourceIdentifier{GVR: core(configMaps), Unreferenced: true, Criteria: Criteria{Metadata: map[string]interface{}{"name": "cm-volume"}}},
+			want: nil,
+		},
+		{
+			name:      "sad - unsupported resource",
+			ri:        ResourceIdentifier{GVR: referrers[0].gvr, Unreferenced: true},
+			wantError: true,
+		},
+	}
+
+	for _, tc := range unreferencedTests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ListResources(client, tc.ri)
+			if tc.wantError != (err != nil) {
+				t.Errorf("got error %v, want error %v", err, tc.wantError)
+			}
+			var names []string
+			for _, item := range got {
+				names = append(names, item.GetNamespace()+"/"+item.GetName())
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("got %v, want %v", names, tc.want)
+			}
+		})
+	}
+}
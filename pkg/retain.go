@@ -0,0 +1,110 @@
+package kln
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Retention keeps the newest Keep objects of every group and selects the
+// rest. Objects are grouped by namespace and by the value at GroupBy, which
+// is a field path when it contains "[" or starts with "metadata.", "spec." or
+// "status.", and a label key otherwise. Objects without that value are not
+// part of any group and are left alone. Objects are ordered by the timestamp
+// at OrderBy, which defaults to the creationTimestamp.
+type Retention struct {
+	Keep    int    `yaml:"keep"`
+	GroupBy string `yaml:"groupBy"`
+	OrderBy string `yaml:"orderBy"`
+}
+
+func (r Retention) validate() error {
+	if r.Keep < 0 {
+		return errors.New("retain.keep cannot be negative")
+	}
+	if r.GroupBy != "" && r.groupByField() {
+		if _, err := parseFieldPath(r.GroupBy); err != nil {
+			return fmt.Errorf("retain.groupBy: %w", err)
+		}
+	}
+	if r.OrderBy != "" {
+		if _, err := parseFieldPath(r.OrderBy); err != nil {
+			return fmt.Errorf("retain.orderBy: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r Retention) groupByField() bool {
+	for _, prefix := range []string{"metadata.", "spec.", "status."} {
+		if strings.HasPrefix(r.GroupBy, prefix) {
+			return true
+		}
+	}
+	return strings.Contains(r.GroupBy, "[")
+}
+
+func (r Retention) groupOf(item unstructured.Unstructured) (string, bool) {
+	if r.GroupBy == "" {
+		return item.GetNamespace(), true
+	}
+	if !r.groupByField() {
+		value, ok := item.GetLabels()[r.GroupBy]
+		return item.GetNamespace() + "/" + value, ok
+	}
+	path, err := parseFieldPath(r.GroupBy)
+	if err != nil {
+		return "", false
+	}
+	value, found := path.lookup(item.Object)
+	if !found || value == nil {
+		return "", false
+	}
+	return item.GetNamespace() + "/" + fmt.Sprint(value), true
+}
+
+// filterByRetention drops the newest Keep objects of every group and
+// returns the rest. Objects without a valid OrderBy timestamp cannot be
+// ordered and are skipped and reported.
+func filterByRetention(responseFromServer []unstructured.Unstructured, retention Retention) []unstructured.Unstructured {
+	type ordered struct {
+		item      unstructured.Unstructured
+		timestamp time.Time
+	}
+	var groupNames []string
+	groups := map[string][]ordered{}
+	for _, item := range responseFromServer {
+		group, ok := retention.groupOf(item)
+		if !ok {
+			continue
+		}
+		timestamp, err := timestampOf(item, retention.OrderBy)
+		if err != nil {
+			WarningLog.Printf("skipping %s/%s: %s", item.GetNamespace(), item.GetName(), err)
+			continue
+		}
+		if _, ok := groups[group]; !ok {
+			groupNames = append(groupNames, group)
+		}
+		groups[group] = append(groups[group], ordered{item, timestamp})
+	}
+
+	var responseList []unstructured.Unstructured
+	for _, group := range groupNames {
+		items := groups[group]
+		sort.SliceStable(items, func(i, j int) bool {
+			if items[i].timestamp.Equal(items[j].timestamp) {
+				return items[i].item.GetName() > items[j].item.GetName()
+			}
+			return items[i].timestamp.After(items[j].timestamp)
+		})
+		for i := retention.Keep; i < len(items); i++ {
+			responseList = append(responseList, items[i].item)
+		}
+	}
+	return responseList
+}
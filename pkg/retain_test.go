@@ -0,0 +1,125 @@
+package kln
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestFilterByRetention(t *testing.T) {
+	now := time.Now()
+	pipelineRun := func(namespace, name, pipeline string, age time.Duration) unstructured.Unstructured {
+		item := unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "tekton.dev/v1beta1", "kind": "PipelineRun"}}
+		item.SetNamespace(namespace)
+		item.SetName(name)
+		item.SetCreationTimestamp(v1.NewTime(now.Add(-age)))
+		if pipeline != "" {
+			item.SetLabels(map[string]string{"tekton.dev/pipeline": pipeline})
+		}
+		return item
+	}
+	job := func(name, cronJob, completionTime string) unstructured.Unstructured {
+		item := unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "batch/v1", "kind": "Job"}}
+		item.SetNamespace("ops")
+		item.SetName(name)
+		if cronJob != "" {
+			item.SetOwnerReferences([]v1.OwnerReference{{APIVersion: "batch/v1", Kind: "CronJob", Name: cronJob}})
+		}
+		if completionTime != "" {
+			unstructured.SetNestedField(item.Object, completionTime, "status", "completionTime")
+		}
+		return item
+	}
+
+	pipelineRuns := []unstructured.Unstructured{
+		pipelineRun("ci", "build-1", "build", 4*time.Hour),
+		pipelineRun("ci", "build-2", "build", 3*time.Hour),
+		pipelineRun("ci", "build-3", "build", 2*time.Hour),
+		pipelineRun("ci", "build-4", "build", 1*time.Hour),
+		pipelineRun("ci", "test-1", "test", 150*time.Minute),
+		pipelineRun("ci", "test-2", "test", 1*time.Hour),
+		pipelineRun("ci", "test-3", "test", 3*time.Hour),
+		pipelineRun("cd", "build-1", "build", 5*time.Hour),
+		pipelineRun("ci", "adhoc", "", 10*time.Hour),
+	}
+	jobs := []unstructured.Unstructured{
+		job("backup-1", "backup", "2022-10-01T00:00:00Z"),
+		job("backup-2", "backup", "2022-10-03T00:00:00Z"),
+		job("backup-3", "backup", "2022-10-02T00:00:00Z"),
+		job("backup-4", "backup", ""),
+		job("report-1", "report", "2022-10-01T00:00:00Z"),
+		job("manual", "", "2022-09-01T00:00:00Z"),
+	}
+
+	retentionTests := []struct {
+		name      string
+		items     []unstructured.Unstructured
+		retention Retention
+		want      []string
+	}{
+		{
+			name:      "happy - keeps the newest per pipeline label and namespace",
+			items:     pipelineRuns,
+			retention: Retention{Keep: 2, GroupBy: "tekton.dev/pipeline"},
+			want:      []string{"ci/build-1", "ci/build-2", "ci/test-3"},
+		},
+		{
+			name:      "happy - keep 0 selects every grouped object",
+			items:     pipelineRuns,
+			retention: Retention{Keep: 0, GroupBy: "tekton.dev/pipeline"},
+			want:      []string{"cd/build-1", "ci/build-1", "ci/build-2", "ci/build-3", "ci/build-4", "ci/test-1", "ci/test-2", "ci/test-3"},
+		},
+		{
+			name:      "happy - without groupBy objects are grouped by namespace",
+			items:     pipelineRuns,
+			retention: Retention{Keep: 3},
+			want:      []string{"ci/adhoc", "ci/build-1", "ci/build-2", "ci/test-1", "ci/test-3"},
+		},
+		{
+			name:      "happy - label given as a field path",
+			items:     pipelineRuns,
+			retention: Retention{Keep: 3, GroupBy: "metadata.labels['tekton.dev/pipeline']"},
+			want:      []string{"ci/build-1"},
+		},
+		{
+			name:      "happy - jobs per CronJob owner ordered by completionTime",
+			items:     jobs,
+			retention: Retention{Keep: 1, GroupBy: "metadata.ownerReferences[kind=CronJob].name", OrderBy: "status.completionTime"},
+			want:      []string{"ops/backup-1", "ops/backup-3"},
+		},
+		{
+			name:      "happy - keeping more than there are selects nothing",
+			items:     jobs,
+			retention: Retention{Keep: 10, GroupBy: "metadata.ownerReferences[kind=CronJob].name"},
+			want:      nil,
+		},
+	}
+
+	for _, tc := range retentionTests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.retention.validate(); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, item := range filterByRetention(tc.items, tc.retention) {
+				got = append(got, item.GetNamespace()+"/"+item.GetName())
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("sad - invalid retention", func(t *testing.T) {
+		for _, retention := range []Retention{{Keep: -1}, {Keep: 1, GroupBy: "metadata.labels[app"}, {Keep: 1, OrderBy: "status..completionTime"}} {
+			if err := retention.validate(); err == nil {
+				t.Errorf("expected %+v to be rejected", retention)
+			}
+		}
+	})
+}
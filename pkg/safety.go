@@ -0,0 +1,120 @@
+package kln
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const ProtectAnnotation = "kln.com/protect"
+
+// SafetyPolicy decides which objects kln never lists, flags or deletes,
+// whatever the resource identifiers say. Objects annotated with
+// "kln.com/protect: true", objects in a protected namespace, the protected
+// Namespaces themselves and objects matched by a deny rule are skipped.
+type SafetyPolicy struct {
+	ProtectedNamespaces []string   `yaml:"protectedNamespaces" json:"protectedNamespaces"`
+	Deny                []DenyRule `yaml:"deny" json:"deny,omitempty"`
+}
+
+// DenyRule matches objects by glob patterns on their group, version, kind,
+// namespace and name. Fields that are not set match anything.
+type DenyRule struct {
+	Group     string `yaml:"group" json:"group,omitempty"`
+	Version   string `yaml:"version" json:"version,omitempty"`
+	Kind      string `yaml:"kind" json:"kind,omitempty"`
+	Namespace string `yaml:"namespace" json:"namespace,omitempty"`
+	Name      string `yaml:"name" json:"name,omitempty"`
+}
+
+// Safety is the safety policy that list, flag and delete apply. Unless the
+// configuration says otherwise, the system namespaces are protected.
+var Safety = SafetyPolicy{ProtectedNamespaces: SystemNamespaces}
+
+func (p SafetyPolicy) Validate() error {
+	for _, pattern := range p.ProtectedNamespaces {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid protected namespace pattern %q: %w", pattern, err)
+		}
+	}
+	for _, rule := range p.Deny {
+		if rule == (DenyRule{}) {
+			return errors.New("deny rules must set at least one of group, version, kind, namespace or name")
+		}
+		for _, pattern := range rule.patterns() {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid deny pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Check returns the reason why the object has to be left alone, or an empty
+// string when kln may act on it
+func (p SafetyPolicy) Check(item unstructured.Unstructured) string {
+	if value, ok := item.GetAnnotations()[ProtectAnnotation]; ok && value == "true" {
+		return fmt.Sprintf("annotated %s=true", ProtectAnnotation)
+	}
+	namespace := item.GetNamespace()
+	if namespace != "" && matchesAnyPattern(namespace, p.ProtectedNamespaces) {
+		return fmt.Sprintf("namespace %s is protected", namespace)
+	}
+	gv, _ := schema.ParseGroupVersion(item.GetAPIVersion())
+	// deleting a Namespace deletes everything in it
+	if gv.Group == "" && item.GetKind() == "Namespace" && matchesAnyPattern(item.GetName(), p.ProtectedNamespaces) {
+		return fmt.Sprintf("namespace %s is protected", item.GetName())
+	}
+	for _, rule := range p.Deny {
+		if rule.matches(gv.WithKind(item.GetKind()), namespace, item.GetName()) {
+			return fmt.Sprintf("denied by rule %s", rule)
+		}
+	}
+	return ""
+}
+
+// filter drops the objects that the safety policy protects and
+// reports each one of them
+func (p SafetyPolicy) filter(responseFromServer []unstructured.Unstructured) []unstructured.Unstructured {
+	var responseList []unstructured.Unstructured
+
+	for _, item := range responseFromServer {
+		if reason := p.Check(item); reason != "" {
+			WarningLog.Printf("skipping %s/%s: %s", item.GetNamespace(), item.GetName(), reason)
+			continue
+		}
+		responseList = append(responseList, item)
+	}
+	return responseList
+}
+
+func (r DenyRule) patterns() []string {
+	return []string{r.Group, r.Version, r.Kind, r.Namespace, r.Name}
+}
+
+func (r DenyRule) matches(gvk schema.GroupVersionKind, namespace, name string) bool {
+	for i, value := range []string{gvk.Group, gvk.Version, gvk.Kind, namespace, name} {
+		pattern := r.patterns()[i]
+		if pattern == "" {
+			continue
+		}
+		if match, _ := path.Match(pattern, value); !match {
+			return false
+		}
+	}
+	return true
+}
+
+func (r DenyRule) String() string {
+	var fields []string
+	for i, name := range []string{"group", "version", "kind", "namespace", "name"} {
+		if pattern := r.patterns()[i]; pattern != "" {
+			fields = append(fields, name+"="+pattern)
+		}
+	}
+	return strings.Join(fields, ",")
+}
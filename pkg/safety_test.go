@@ -0,0 +1,179 @@
+package kln
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestSafetyPolicyCheck(t *testing.T) {
+	policy := SafetyPolicy{
+		ProtectedNamespaces: []string{"kube-*", "prod"},
+		Deny: []DenyRule{
+			{Kind: "Secret", Name: "sh.helm.release.*"},
+			{Group: "tekton.dev", Namespace: "ci", Name: "release-*"},
+		},
+	}
+	object := func(apiVersion, kind, namespace, name string, annotations map[string]string) unstructured.Unstructured {
+		item := unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": apiVersion, "kind": kind}}
+		item.SetNamespace(namespace)
+		item.SetName(name)
+		item.SetAnnotations(annotations)
+		return item
+	}
+
+	checkTests := []struct {
+		name   string
+		item   unstructured.Unstructured
+		reason string
+	}{
+		{
+			name: "happy - unprotected object",
+			item: object("v1", "ConfigMap", "preview", "settings", nil),
+		},
+		{
+			name:   "happy - protect annotation",
+			item:   object("v1", "ConfigMap", "preview", "settings", map[string]string{ProtectAnnotation: "true"}),
+			reason: "annotated kln.com/protect=true",
+		},
+		{
+			name: "happy - protect annotation must be true",
+			item: object("v1", "ConfigMap", "preview", "settings", map[string]string{ProtectAnnotation: "false"}),
+		},
+		{
+			name:   "happy - protected namespace pattern",
+			item:   object("v1", "Pod", "kube-system", "coredns", nil),
+			reason: "namespace kube-system is protected",
+		},
+		{
+			name:   "happy - protected namespace",
+			item:   object("batch/v1", "Job", "prod", "migrate", nil),
+			reason: "namespace prod is protected",
+		},
+		{
+			name:   "happy - protected namespaces protect their Namespace object",
+			item:   object("v1", "Namespace", "", "kube-system", nil),
+			reason: "namespace kube-system is protected",
+		},
+		{
+			name: "happy - other Namespace objects are not protected",
+			item: object("v1", "Namespace", "", "preview", nil),
+		},
+		{
+			name: "happy - other cluster scoped objects are not in a protected namespace",
+			item: object("rbac.authorization.k8s.io/v1", "ClusterRole", "", "kube-system", nil),
+		},
+		{
+			name:   "happy - deny rule on kind and name",
+			item:   object("v1", "Secret", "preview", "sh.helm.release.v1.web.v3", nil),
+			reason: "denied by rule kind=Secret,name=sh.helm.release.*",
+		},
+		{
+			name:   "happy - deny rule on group and namespace",
+			item:   object("tekton.dev/v1beta1", "PipelineRun", "ci", "release-1-2", nil),
+			reason: "denied by rule group=tekton.dev,namespace=ci,name=release-*",
+		},
+		{
+			name: "happy - deny rule needs every field to match",
+			item: object("tekton.dev/v1beta1", "PipelineRun", "cd", "release-1-2", nil),
+		},
+	}
+
+	for _, tc := range checkTests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := policy.Check(tc.item); got != tc.reason {
+				t.Errorf("got %q, want %q", got, tc.reason)
+			}
+		})
+	}
+}
+
+func TestSafetyPolicyValidate(t *testing.T) {
+	for _, policy := range []SafetyPolicy{
+		{ProtectedNamespaces: []string{"kube-["}},
+		{Deny: []DenyRule{{}}},
+		{Deny: []DenyRule{{Name: "release-["}}},
+	} {
+		if err := policy.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", policy)
+		}
+	}
+	if err := Safety.Validate(); err != nil {
+		t.Errorf("expected the default policy to be valid but got %s", err)
+	}
+}
+
+func TestSafetyInListFlagAndDelete(t *testing.T) {
+	defer func(safety SafetyPolicy) { Safety = safety }(Safety)
+	Safety = SafetyPolicy{ProtectedNamespaces: []string{"prod"}, Deny: []DenyRule{{Name: "keep-*"}}}
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		aGVRK.GVR: aGVRK.Kind + "List",
+	})
+	for _, object := range []struct {
+		namespace   string
+		name        string
+		annotations map[string]string
+	}{
+		{"preview", "web", nil},
+		{"preview", "protected", map[string]string{ProtectAnnotation: "true"}},
+		{"preview", "keep-me", nil},
+		{"prod", "web", nil},
+	} {
+		item := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": aGVRK.GVR.Group + "/" + aGVRK.GVR.Version, "kind": aGVRK.Kind}}
+		item.SetNamespace(object.namespace)
+		item.SetName(object.name)
+		item.SetAnnotations(object.annotations)
+		item.SetLabels(map[string]string{"kln.com/delete": "true"})
+		if _, err := client.Resource(aGVRK.GVR).Namespace(object.namespace).Create(context.TODO(), item, v1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func(items []unstructured.Unstructured) string {
+		var names []string
+		for _, item := range items {
+			names = append(names, item.GetNamespace()+"/"+item.GetName())
+		}
+		sort.Strings(names)
+		return strings.Join(names, ",")
+	}
+	ri := ResourceIdentifier{GVR: aGVRK.GVR}
+
+	t.Run("happy - list skips protected objects", func(t *testing.T) {
+		got, err := ListResources(client, ri)
+		if err != nil {
+			t.Error(err)
+		}
+		if names(got) != "preview/web" {
+			t.Errorf("got %s, want preview/web", names(got))
+		}
+	})
+
+	t.Run("happy - flag skips protected objects", func(t *testing.T) {
+		if _, err := FlagForDeletion(client, ri, false, DryRunNone); err != nil {
+			t.Error(err)
+		}
+		got, _ := client.Resource(aGVRK.GVR).List(context.TODO(), v1.ListOptions{LabelSelector: "kln.com/delete=false"})
+		if names(got.Items) != "preview/web" {
+			t.Errorf("got %s unflagged, want preview/web", names(got.Items))
+		}
+		client.Resource(aGVRK.GVR).Namespace("preview").Patch(context.TODO(), "web", "application/merge-patch+json", []byte(`{"metadata":{"labels":{"kln.com/delete":"true"}}}`), v1.PatchOptions{})
+	})
+
+	t.Run("happy - delete skips protected objects", func(t *testing.T) {
+		if _, err := DeleteResources(client, ri.GVR, nil, DeletePolicy{}, true, DryRunNone); err != nil {
+			t.Error(err)
+		}
+		got, _ := client.Resource(aGVRK.GVR).List(context.TODO(), v1.ListOptions{})
+		if names(got.Items) != "preview/keep-me,preview/protected,prod/web" {
+			t.Errorf("got %s left, want the protected objects", names(got.Items))
+		}
+	})
+}
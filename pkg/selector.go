@@ -0,0 +1,61 @@
+package kln
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// listOptions builds the options of the List call so that the api server
+// does as much of the filtering as possible. Besides the label and field
+// selectors of the resource identifier, selectors are derived from literal
+// labels, namespace and name in its metadata criteria. The criteria are
+// still matched against every object that is returned, so criteria that
+// cannot be expressed as selectors are simply left out.
+func (ri ResourceIdentifier) listOptions() (v1.ListOptions, error) {
+	if _, err := labels.Parse(ri.LabelSelector); err != nil {
+		return v1.ListOptions{}, fmt.Errorf("invalid labelSelector: %w", err)
+	}
+	if _, err := fields.ParseSelector(ri.FieldSelector); err != nil {
+		return v1.ListOptions{}, fmt.Errorf("invalid fieldSelector: %w", err)
+	}
+
+	var labelSelector []string
+	if ri.LabelSelector != "" {
+		labelSelector = append(labelSelector, ri.LabelSelector)
+	}
+	if criteria, ok := ri.Metadata["labels"].(map[string]interface{}); ok {
+		var derived []string
+		for key, value := range criteria {
+			s, ok := value.(string)
+			if !ok {
+				continue
+			}
+			if _, err := (labels.Set{key: s}).AsValidatedSelector(); err != nil {
+				continue
+			}
+			derived = append(derived, key+"="+s)
+		}
+		sort.Strings(derived)
+		labelSelector = append(labelSelector, derived...)
+	}
+
+	var fieldSelector []string
+	if ri.FieldSelector != "" {
+		fieldSelector = append(fieldSelector, ri.FieldSelector)
+	}
+	for _, field := range []string{"namespace", "name"} {
+		if s, ok := ri.Metadata[field].(string); ok && s != "" {
+			fieldSelector = append(fieldSelector, "metadata."+field+"="+fields.EscapeValue(s))
+		}
+	}
+
+	return v1.ListOptions{
+		LabelSelector: strings.Join(labelSelector, ","),
+		FieldSelector: strings.Join(fieldSelector, ","),
+	}, nil
+}
@@ -0,0 +1,83 @@
+package kln
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestListOptions(t *testing.T) {
+	listOptionsTests := []struct {
+		name              string
+		ri                string
+		wantLabelSelector string
+		wantFieldSelector string
+		wantError         bool
+	}{
+		{
+			name: "happy - no selectors",
+			ri:   `status: {conditions: [{type: Complete}]}`,
+		},
+		{
+			name:              "happy - explicit selectors",
+			ri:                `{labelSelector: "app in (preview, review)", fieldSelector: "status.phase=Succeeded"}`,
+			wantLabelSelector: "app in (preview, review)",
+			wantFieldSelector: "status.phase=Succeeded",
+		},
+		{
+			name:              "happy - derived from literal labels",
+			ri:                `metadata: {labels: {tekton.dev/pipeline: build, app: ci}}`,
+			wantLabelSelector: "app=ci,tekton.dev/pipeline=build",
+		},
+		{
+			name:              "happy - operators and invalid values are not derived",
+			ri:                `metadata: {labels: {app: {$in: [ci]}, keep: {$exists: false}, owner: "not a label value", tier: 2, team: ci}}`,
+			wantLabelSelector: "team=ci",
+		},
+		{
+			name:              "happy - derived from namespace and name",
+			ri:                `metadata: {namespace: ci, name: build-x7k2p, uid: "1234"}`,
+			wantFieldSelector: "metadata.namespace=ci,metadata.name=build-x7k2p",
+		},
+		{
+			name:              "happy - operators on namespace are not derived",
+			ri:                `metadata: {namespace: {$regex: "^preview-"}}`,
+			wantFieldSelector: "",
+		},
+		{
+			name:              "happy - explicit and derived selectors are combined",
+			ri:                `{labelSelector: "!keep", fieldSelector: "status.phase=Failed", metadata: {namespace: ci, labels: {app: ci}}}`,
+			wantLabelSelector: "!keep,app=ci",
+			wantFieldSelector: "status.phase=Failed,metadata.namespace=ci",
+		},
+		{
+			name:      "sad - invalid labelSelector",
+			ri:        `labelSelector: "app in (preview"`,
+			wantError: true,
+		},
+		{
+			name:      "sad - invalid fieldSelector",
+			ri:        `fieldSelector: "status.phase"`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range listOptionsTests {
+		t.Run(tc.name, func(t *testing.T) {
+			var ri ResourceIdentifier
+			if err := yaml.Unmarshal([]byte(tc.ri), &ri); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ri.listOptions()
+			if tc.wantError != (err != nil) {
+				t.Errorf("got error %v, want error %v", err, tc.wantError)
+			}
+			if got.LabelSelector != tc.wantLabelSelector {
+				t.Errorf("got labelSelector %q, want %q", got.LabelSelector, tc.wantLabelSelector)
+			}
+			if got.FieldSelector != tc.wantFieldSelector {
+				t.Errorf("got fieldSelector %q, want %q", got.FieldSelector, tc.wantFieldSelector)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package kln
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// schemaChecker walks a yaml node tree along the go type that it is going to
+// be decoded into and collects every unknown field and every value of the
+// wrong type, with its position. yaml.v3 can reject unknown fields too, but it
+// stops at the first error and does not report columns.
+type schemaChecker struct {
+	file string
+	errs ValidationErrors
+}
+
+func (c *schemaChecker) check(node *yaml.Node, t reflect.Type, path string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" {
+		return
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		c.checkScalar(node, t, path)
+		return
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		c.check(node, t.Elem(), path)
+	case reflect.Interface:
+	case reflect.Struct:
+		if !c.expectKind(node, yaml.MappingNode, path) {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.ShortTag() == "!!merge" {
+				c.checkMerge(value, t, path)
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				c.errorf(key, joinPath(path, key.Value), "unknown field %q%s", key.Value, suggest(key.Value, fieldNames(fields)))
+				continue
+			}
+			c.check(value, field, joinPath(path, key.Value))
+		}
+	case reflect.Map:
+		if !c.expectKind(node, yaml.MappingNode, path) {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			c.check(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value))
+		}
+	case reflect.Slice:
+		if !c.expectKind(node, yaml.SequenceNode, path) {
+			return
+		}
+		for i, item := range node.Content {
+			c.check(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	default:
+		c.checkScalar(node, t, path)
+	}
+}
+
+// checkMerge checks the mappings that are merged into a mapping with "<<"
+func (c *schemaChecker) checkMerge(node *yaml.Node, t reflect.Type, path string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.SequenceNode {
+		for _, item := range node.Content {
+			c.check(item, t, path)
+		}
+		return
+	}
+	c.check(node, t, path)
+}
+
+func (c *schemaChecker) checkScalar(node *yaml.Node, t reflect.Type, path string) {
+	if !c.expectKind(node, yaml.ScalarNode, path) {
+		return
+	}
+	value := reflect.New(t)
+	if err := node.Decode(value.Interface()); err != nil {
+		if reflect.PtrTo(t).Implements(unmarshalerType) {
+			c.errorf(node, path, "%s", err)
+		} else {
+			c.errorf(node, path, "expected %s but got %q", describeType(t), node.Value)
+		}
+		return
+	}
+	if age, ok := value.Interface().(*Age); ok && *age < 0 {
+		c.errorf(node, path, "age cannot be negative")
+	}
+}
+
+func (c *schemaChecker) expectKind(node *yaml.Node, kind yaml.Kind, path string) bool {
+	if node.Kind == kind {
+		return true
+	}
+	expected := map[yaml.Kind]string{yaml.MappingNode: "a mapping", yaml.SequenceNode: "a list", yaml.ScalarNode: "a value"}[kind]
+	c.errorf(node, path, "expected %s but got %s", expected, describeNode(node))
+	return false
+}
+
+func (c *schemaChecker) errorf(node *yaml.Node, path, format string, args ...interface{}) {
+	c.errs = append(c.errs, ValidationError{
+		File:    c.file,
+		Line:    node.Line,
+		Column:  node.Column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// yamlFields maps the yaml keys of a struct to the types of their fields,
+// including the fields of inlined structs. Like yaml.v3, fields without a
+// name in their tag are keyed by their lower cased go name.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag := field.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if options == "inline" && field.Type.Kind() == reflect.Struct {
+			for key, value := range yamlFields(field.Type) {
+				fields[key] = value
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func fieldNames(fields map[string]reflect.Type) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	return names
+}
+
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	}
+	return "a " + t.Kind().String()
+}
+
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	return fmt.Sprintf("%q", node.Value)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,78 @@
+package kln
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	TTLAnnotation     = "kln.com/ttl"
+	ExpiresAnnotation = "kln.com/expires"
+)
+
+// expired reports whether the lifetime that the object declares in its ttl
+// or expires annotation is over. The ttl is measured from the
+// creationTimestamp. ok is false when the object has neither annotation.
+func expired(item unstructured.Unstructured, now time.Time) (isExpired, ok bool, err error) {
+	annotations := item.GetAnnotations()
+	ttl, hasTTL := annotations[TTLAnnotation]
+	expires, hasExpires := annotations[ExpiresAnnotation]
+	if !hasTTL && !hasExpires {
+		return false, false, nil
+	}
+
+	if hasTTL {
+		age, err := ParseAge(ttl)
+		if err != nil || age < 0 {
+			return false, true, skipError{fmt.Sprintf("invalid %s annotation %q", TTLAnnotation, ttl)}
+		}
+		if now.Sub(item.GetCreationTimestamp().Time) > age.Duration() {
+			isExpired = true
+		}
+	}
+	if hasExpires {
+		timestamp, err := parseExpires(expires)
+		if err != nil {
+			return false, true, skipError{fmt.Sprintf("invalid %s annotation %q", ExpiresAnnotation, expires)}
+		}
+		if now.After(timestamp) {
+			isExpired = true
+		}
+	}
+	return isExpired, true, nil
+}
+
+func parseExpires(expires string) (time.Time, error) {
+	for _, layout := range []string{RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if timestamp, err := time.Parse(layout, expires); err == nil {
+			return timestamp, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", expires)
+}
+
+// partitionByTTL takes the objects that carry a ttl or expires annotation
+// out of the list. Their declared lifetime takes precedence over the
+// criteria of the resource identifier, so the expired ones are returned to
+// be selected without the criteria, only subject to the unreferenced and
+// ownership guards, and the others are left alone. Objects with a malformed
+// annotation are skipped and reported.
+func partitionByTTL(responseFromServer []unstructured.Unstructured, now time.Time) (unannotated, expiredList []unstructured.Unstructured) {
+	for _, item := range responseFromServer {
+		isExpired, ok, err := expired(item, now)
+		if err != nil {
+			WarningLog.Printf("skipping %s/%s: %s", item.GetNamespace(), item.GetName(), err)
+			continue
+		}
+		if !ok {
+			unannotated = append(unannotated, item)
+			continue
+		}
+		if isExpired {
+			expiredList = append(expiredList, item)
+		}
+	}
+	return unannotated, expiredList
+}
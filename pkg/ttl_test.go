@@ -0,0 +1,129 @@
+package kln
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	discoveryfake "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/restmapper"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestListResourcesTTLAnnotations(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		aGVRK.GVR: aGVRK.Kind + "List",
+	})
+	now := time.Now()
+	owner := v1.OwnerReference{APIVersion: aGVRK.GVR.Group + "/" + aGVRK.GVR.Version, Kind: aGVRK.Kind, Name: "owner", UID: "owner-1"}
+	objects := []struct {
+		name        string
+		age         time.Duration
+		annotations map[string]string
+		owners      []v1.OwnerReference
+	}{
+		{name: "owner", age: time.Hour},
+		{name: "ttl-expired-owned", age: 25 * time.Hour, annotations: map[string]string{TTLAnnotation: "24h"}, owners: []v1.OwnerReference{owner}},
+		{name: "ttl-expired", age: 25 * time.Hour, annotations: map[string]string{TTLAnnotation: "24h"}},
+		{name: "ttl-days-expired", age: 73 * time.Hour, annotations: map[string]string{TTLAnnotation: "3d"}},
+		{name: "ttl-alive", age: 25 * time.Hour, annotations: map[string]string{TTLAnnotation: "2d"}},
+		{name: "expires-expired", age: time.Hour, annotations: map[string]string{ExpiresAnnotation: now.Add(-time.Minute).Format(RFC3339)}},
+		{name: "expires-date-expired", age: time.Hour, annotations: map[string]string{ExpiresAnnotation: "2022-11-01"}},
+		{name: "expires-alive", age: 100 * time.Hour, annotations: map[string]string{ExpiresAnnotation: now.Add(time.Hour).Format(RFC3339)}},
+		{name: "either-expired", age: 2 * time.Hour, annotations: map[string]string{TTLAnnotation: "1h", ExpiresAnnotation: now.Add(time.Hour).Format(RFC3339)}},
+		{name: "ttl-malformed", age: 100 * time.Hour, annotations: map[string]string{TTLAnnotation: "forever"}},
+		{name: "expires-malformed", age: 100 * time.Hour, annotations: map[string]string{ExpiresAnnotation: "next week"}},
+		{name: "unannotated-old", age: 100 * time.Hour},
+		{name: "unannotated-young", age: time.Hour},
+	}
+	for _, object := range objects {
+		item := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": aGVRK.GVR.Group + "/" + aGVRK.GVR.Version, "kind": aGVRK.Kind}}
+		item.SetNamespace("preview")
+		item.SetName(object.name)
+		item.SetCreationTimestamp(v1.NewTime(now.Add(-object.age)))
+		item.SetAnnotations(object.annotations)
+		item.SetOwnerReferences(object.owners)
+		if object.name == "owner" {
+			item.SetUID(owner.UID)
+		}
+		if _, err := client.Resource(aGVRK.GVR).Namespace("preview").Create(context.TODO(), item, v1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ttlTests := []struct {
+		name string
+		ri   ResourceIdentifier
+		want []string
+	}{
+		{
+			name: "happy - expired annotations take precedence over the criteria",
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, HonorTTLAnnotations: true, Criteria: Criteria{MinAge: 72}},
+			want: []string{"either-expired", "expires-date-expired", "expires-expired", "ttl-days-expired", "ttl-expired", "ttl-expired-owned", "unannotated-old"},
+		},
+		{
+			name: "happy - the ownership guard applies to expired objects too",
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, HonorTTLAnnotations: true, Ownership: OwnershipOrphaned, Criteria: Criteria{MinAge: 72}},
+			want: []string{"either-expired", "expires-date-expired", "expires-expired", "ttl-days-expired", "ttl-expired", "unannotated-old"},
+		},
+		{
+			name: "happy - annotations are ignored unless honored",
+			ri:   ResourceIdentifier{GVR: aGVRK.GVR, Criteria: Criteria{MinAge: 72}},
+			want: []string{"expires-alive", "expires-malformed", "ttl-days-expired", "ttl-malformed", "unannotated-old"},
+		},
+	}
+
+	groups, err := restmapper.GetAPIGroupResources(&discoveryfake.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*v1.APIResourceList{
+		{GroupVersion: aGVRK.GVR.Group + "/" + aGVRK.GVR.Version, APIResources: []v1.APIResource{{Name: aGVRK.GVR.Resource, Kind: aGVRK.Kind, Namespaced: true}}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapper := restmapper.NewDiscoveryRESTMapper(groups)
+
+	for _, tc := range ttlTests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.ri.mapper = mapper
+			got, err := ListResources(client, tc.ri)
+			if err != nil {
+				t.Error(err)
+			}
+			var names []string
+			for _, item := range got {
+				names = append(names, item.GetName())
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("got %v, want %v", names, tc.want)
+			}
+		})
+	}
+}
+
+func TestPrintMatchesTTLCriteria(t *testing.T) {
+	ri := ResourceIdentifier{Name: "previews", GVR: aGVRK.GVR, HonorTTLAnnotations: true, Criteria: Criteria{MinAge: 72}}
+	expiredItem := r1.DeepCopy()
+	expiredItem.SetAnnotations(map[string]string{ExpiresAnnotation: "2022-11-01"})
+	var buf bytes.Buffer
+	if err := PrintMatches(&buf, OutputWide, []Match{{RI: ri, Object: *expiredItem}, {RI: ri, Object: *r3}}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and 2 rows but got\n%s", buf.String())
+	}
+	if fields := strings.Fields(lines[1]); fields[len(fields)-1] != "ttlAnnotations=expired" {
+		t.Errorf("unexpected criteria for the expired object %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); fields[len(fields)-1] != "minAge=72h,ttlAnnotations" {
+		t.Errorf("unexpected criteria for the matched object %q", lines[2])
+	}
+}
@@ -0,0 +1,132 @@
+package kln
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
+)
+
+// WildcardResource is the resource that selects every resource of a group
+const WildcardResource = "*"
+
+func (ri ResourceIdentifier) isWildcard() bool {
+	return ri.Resource != "" || ri.AllNamespacedResources
+}
+
+func (ri ResourceIdentifier) validateWildcard(hasGVR bool) (string, error) {
+	switch {
+	case ri.Resource != "" && ri.Resource != WildcardResource:
+		return "resource", fmt.Errorf("resource can only be %q, use gvr or kind to select a single resource", WildcardResource)
+	case ri.AllNamespacedResources && (ri.Resource != "" || ri.Kind != "" || hasGVR || ri.APIVersion != "" || ri.Group != ""):
+		return "", errors.New("allNamespacedResources cannot be set together with gvr, apiVersion, group, kind or resource")
+	case ri.Kind != "" || hasGVR:
+		return "resource", errors.New("resource cannot be set together with gvr or kind")
+	case ri.APIVersion != "" && ri.Group != "":
+		return "group", errors.New("group cannot be set together with apiVersion")
+	case ri.Resource != "" && ri.APIVersion == "" && ri.Group == "":
+		return "resource", fmt.Errorf("resource %q requires a group or an apiVersion", WildcardResource)
+	case ri.Unreferenced:
+		return "unreferenced", errors.New("unreferenced cannot be used with wildcards")
+	}
+	if ri.APIVersion != "" {
+		if _, err := schema.ParseGroupVersion(ri.APIVersion); err != nil {
+			return "apiVersion", err
+		}
+	}
+	return "", nil
+}
+
+// expandWildcards replaces every wildcard resource identifier with one
+// resource identifier per resource that it matches. Only resources that can
+// be listed are matched, subresources are not. Cluster scoped resources are
+// left out when the wildcard selects namespaces.
+func (c *Config) expandWildcards(groups []*restmapper.APIGroupResources) ValidationErrors {
+	var errs ValidationErrors
+	var items []ResourceIdentifier
+	var sources []itemSource
+	for i, ri := range c.Items {
+		if !ri.isWildcard() {
+			items = append(items, ri)
+			sources = append(sources, c.sources[i])
+			continue
+		}
+		expanded := ri.expand(groups)
+		if len(expanded) == 0 {
+			errs = append(errs, c.itemError(i, "", fmt.Sprintf("the cluster does not serve any resource that can be listed for %s", ri.target())))
+		}
+		for _, expandedRI := range expanded {
+			items = append(items, expandedRI)
+			sources = append(sources, c.sources[i])
+		}
+	}
+	c.Items, c.sources = items, sources
+	return errs
+}
+
+func (ri ResourceIdentifier) expand(groups []*restmapper.APIGroupResources) []ResourceIdentifier {
+	var expanded []ResourceIdentifier
+	for _, group := range groups {
+		version := group.Group.PreferredVersion.Version
+		if !ri.AllNamespacedResources {
+			groupName := ri.Group
+			if ri.APIVersion != "" {
+				gv, _ := schema.ParseGroupVersion(ri.APIVersion)
+				groupName, version = gv.Group, gv.Version
+			}
+			if group.Group.Name != groupName {
+				continue
+			}
+		}
+		for _, resource := range group.VersionedResources[version] {
+			if strings.Contains(resource.Name, "/") || !containsString(resource.Verbs, "list") {
+				continue
+			}
+			if !resource.Namespaced && (ri.AllNamespacedResources || len(ri.Namespaces) != 0) {
+				continue
+			}
+			expandedRI := ri
+			expandedRI.GVR = schema.GroupVersionResource{Group: group.Group.Name, Version: version, Resource: resource.Name}
+			expandedRI.APIVersion, expandedRI.Group, expandedRI.Resource, expandedRI.AllNamespacedResources = "", "", "", false
+			expanded = append(expanded, expandedRI)
+		}
+	}
+	sort.Slice(expanded, func(i, j int) bool {
+		if expanded[i].GVR.Group != expanded[j].GVR.Group {
+			return expanded[i].GVR.Group < expanded[j].GVR.Group
+		}
+		return expanded[i].GVR.Resource < expanded[j].GVR.Resource
+	})
+	return expanded
+}
+
+// DeletableResources returns every resource that the cluster serves and
+// that can be both listed and deleted, at the preferred version of its
+// group, sorted by group and resource. Subresources are left out.
+func DeletableResources(client discovery.DiscoveryInterface) ([]schema.GroupVersionResource, error) {
+	groups, err := restmapper.GetAPIGroupResources(client)
+	if err != nil {
+		return nil, err
+	}
+	var gvrs []schema.GroupVersionResource
+	for _, group := range groups {
+		version := group.Group.PreferredVersion.Version
+		for _, resource := range group.VersionedResources[version] {
+			if strings.Contains(resource.Name, "/") || !containsString(resource.Verbs, "list") || !containsString(resource.Verbs, "delete") {
+				continue
+			}
+			gvrs = append(gvrs, schema.GroupVersionResource{Group: group.Group.Name, Version: version, Resource: resource.Name})
+		}
+	}
+	sort.Slice(gvrs, func(i, j int) bool {
+		if gvrs[i].Group != gvrs[j].Group {
+			return gvrs[i].Group < gvrs[j].Group
+		}
+		return gvrs[i].Resource < gvrs[j].Resource
+	})
+	return gvrs, nil
+}
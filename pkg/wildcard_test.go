@@ -0,0 +1,160 @@
+package kln
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	discoveryfake "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestExpandWildcards(t *testing.T) {
+	verbs := metav1.Verbs{"delete", "get", "list", "patch"}
+	client := &discoveryfake.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: verbs},
+				{Name: "pods/log", Kind: "Pod", Namespaced: true, Verbs: metav1.Verbs{"get"}},
+				{Name: "bindings", Kind: "Binding", Namespaced: true, Verbs: metav1.Verbs{"create"}},
+				{Name: "namespaces", Kind: "Namespace", Verbs: verbs},
+			},
+		},
+		{
+			GroupVersion: "tekton.dev/v1beta1",
+			APIResources: []metav1.APIResource{
+				{Name: "taskruns", Kind: "TaskRun", Namespaced: true, Verbs: verbs},
+				{Name: "pipelineruns", Kind: "PipelineRun", Namespaced: true, Verbs: verbs},
+				{Name: "pipelineruns/status", Kind: "PipelineRun", Namespaced: true, Verbs: verbs},
+				{Name: "clustertasks", Kind: "ClusterTask", Verbs: verbs},
+			},
+		},
+		{
+			GroupVersion: "tekton.dev/v1alpha1",
+			APIResources: []metav1.APIResource{
+				{Name: "runs", Kind: "Run", Namespaced: true, Verbs: verbs},
+			},
+		},
+	}}}
+
+	expandTests := []struct {
+		name   string
+		config string
+		want   string
+		err    string
+	}{
+		{
+			name:   "happy - every listable resource of a group at its preferred version",
+			config: "group: tekton.dev\n    resource: '*'\n    minAge: 1",
+			want:   "clustertasks.v1beta1.tekton.dev:true pipelineruns.v1beta1.tekton.dev:false taskruns.v1beta1.tekton.dev:false",
+		},
+		{
+			name:   "happy - every listable resource of a version",
+			config: "apiVersion: tekton.dev/v1alpha1\n    resource: '*'\n    minAge: 1",
+			want:   "runs.v1alpha1.tekton.dev:false",
+		},
+		{
+			name:   "happy - cluster scoped resources are left out when selecting namespaces",
+			config: "group: tekton.dev\n    resource: '*'\n    namespaces: [ci]",
+			want:   "pipelineruns.v1beta1.tekton.dev:false taskruns.v1beta1.tekton.dev:false",
+		},
+		{
+			name:   "happy - every namespaced resource",
+			config: "allNamespacedResources: true\n    labelSelector: preview=true",
+			want:   "pods.v1:false pipelineruns.v1beta1.tekton.dev:false taskruns.v1beta1.tekton.dev:false",
+		},
+		{
+			name:   "sad - group without resources",
+			config: "group: example.com\n    resource: '*'\n    minAge: 1",
+			err:    "test.yaml:2:5: items[0]: the cluster does not serve any resource that can be listed for every resource in example.com",
+		},
+	}
+
+	for _, tc := range expandTests {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := ParseConfig("test.yaml", []byte("items:\n  - name: wildcard\n    "+tc.config+"\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = config.Resolve(client)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Errorf("got error %v, want %s", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for i, ri := range config.Items {
+				got = append(got, formatGVR(ri.GVR)+":"+map[bool]string{true: "true", false: "false"}[ri.clusterScoped])
+				if ri.Name != "wildcard" || ri.isWildcard() || config.sources[i].path != "items[0]" {
+					t.Errorf("expected expanded resource identifiers to keep their name and source but got %+v", ri)
+				}
+			}
+			if strings.Join(got, " ") != tc.want {
+				t.Errorf("got %v, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateWildcard(t *testing.T) {
+	validateTests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{name: "sad - resource can only be a wildcard", config: "group: batch\n    resource: jobs", err: `items[0].resource: resource can only be "*"`},
+		{name: "sad - wildcard needs a group", config: "resource: '*'", err: `items[0].resource: resource "*" requires a group or an apiVersion`},
+		{name: "sad - wildcard with kind", config: "resource: '*'\n    kind: Job", err: "items[0].resource: resource cannot be set together with gvr or kind"},
+		{name: "sad - all namespaced resources with a group", config: "allNamespacedResources: true\n    group: batch", err: "items[0]: allNamespacedResources cannot be set together"},
+		{name: "sad - group and apiVersion", config: "group: batch\n    apiVersion: batch/v1\n    resource: '*'", err: "items[0].group: group cannot be set together with apiVersion"},
+		{name: "sad - unreferenced with wildcard", config: "apiVersion: v1\n    resource: '*'\n    unreferenced: true", err: "items[0].unreferenced: unreferenced cannot be used with wildcards"},
+		{name: "sad - wildcard without criteria", config: "allNamespacedResources: true", err: "items[0]: selects every object of every namespaced resource, set at least one criterion"},
+	}
+
+	for _, tc := range validateTests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseConfig("test.yaml", []byte("items:\n  - "+tc.config+"\n"))
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("got %v, want %s", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestDeletableResources(t *testing.T) {
+	verbs := metav1.Verbs{"delete", "get", "list"}
+	client := &discoveryfake.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: verbs},
+				{Name: "pods/log", Kind: "Pod", Namespaced: true, Verbs: verbs},
+				{Name: "componentstatuses", Kind: "ComponentStatus", Verbs: metav1.Verbs{"get", "list"}},
+				{Name: "namespaces", Kind: "Namespace", Verbs: verbs},
+			},
+		},
+		{
+			GroupVersion: "batch/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "jobs", Kind: "Job", Namespaced: true, Verbs: verbs},
+			},
+		},
+	}}}
+
+	gvrs, err := DeletableResources(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, gvr := range gvrs {
+		got = append(got, formatGVR(gvr))
+	}
+	if want := "namespaces.v1 pods.v1 jobs.v1.batch"; strings.Join(got, " ") != want {
+		t.Errorf("got %s, want %s", strings.Join(got, " "), want)
+	}
+}